@@ -0,0 +1,233 @@
+// Package cli implements the "spms cli" command-line interface to the vault.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"spms/crypto"
+	"spms/db"
+	"spms/vaults"
+	"strings"
+)
+
+const usage = `Usage: spms cli [options] <command> [arguments]
+
+Commands:
+  init       create a new vault
+  unlock     check the master password and upgrade the vault if needed
+  ls         list entries
+  show       show an entry
+  add        add an entry
+  edit       change an entry
+  rm         move an entry to the trash
+  generate   generate a password
+  passwd     change the master password
+  cipher     show or change the cipher used for entries
+  export     export all entries as JSON or CSV
+  import     import entries exported from another password manager
+  vaults     list named vaults
+
+Options:
+`
+
+// errUsage is returned by commands called with invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+type command func(e *env, args []string) error
+
+var commands = map[string]command{
+	"init":     runInit,
+	"unlock":   runUnlock,
+	"ls":       runList,
+	"show":     runShow,
+	"add":      runAdd,
+	"edit":     runEdit,
+	"rm":       runRemove,
+	"generate": runGenerate,
+	"passwd":   runPasswd,
+	"cipher":   runCipher,
+	"export":   runExport,
+	"import":   runImport,
+	"vaults":   runVaults,
+}
+
+// env is the state shared by a command invocation.
+type env struct {
+	vaultFlag string
+	vaultPath string
+	json      bool
+	stdin     *os.File
+	in        *bufio.Reader
+	out       io.Writer
+	errOut    io.Writer
+	db        *db.DB
+}
+
+// Run executes the command line args (without the leading "cli") and
+// returns the process exit code.
+func Run(args []string) int {
+	e := &env{
+		stdin:  os.Stdin,
+		in:     bufio.NewReader(os.Stdin),
+		out:    os.Stdout,
+		errOut: os.Stderr,
+	}
+
+	fs := flag.NewFlagSet("spms cli", flag.ContinueOnError)
+	fs.SetOutput(e.errOut)
+	fs.StringVar(&e.vaultFlag, "vault", "", "vault name or path (default $"+vaults.EnvVault+" or the default vault)")
+	fs.BoolVar(&e.json, "json", false, "print machine-readable JSON")
+	fs.Usage = func() {
+		fmt.Fprint(e.errOut, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(e.errOut, "unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	vaultPath, err := vaults.Resolve(e.vaultFlag)
+	if err != nil {
+		fmt.Fprintln(e.errOut, "error:", err)
+		return 1
+	}
+	e.vaultPath = vaultPath
+
+	err = cmd(e, fs.Args()[1:])
+	if e.db != nil {
+		e.db.Close()
+	}
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintln(e.errOut, "error:", err)
+		return 1
+	}
+}
+
+// openExisting opens the vault, failing instead of creating an empty one
+// when it does not exist yet. A missing default vault can be replaced by a
+// copy of the vault.db of an older version once the user agrees.
+func (e *env) openExisting() (*db.DB, error) {
+	if _, err := os.Stat(e.vaultPath); errors.Is(err, os.ErrNotExist) {
+		legacy, ok := vaults.FindLegacy(e.vaultFlag)
+		if !ok {
+			return nil, fmt.Errorf("vault %s does not exist; run spms cli init", e.vaultPath)
+		}
+		if err := e.copyLegacy(legacy); err != nil {
+			return nil, err
+		}
+	}
+	return e.open()
+}
+
+// copyLegacy asks whether to copy the legacy vault to the default vault and
+// does so. Without a terminal to ask on, nothing is copied.
+func (e *env) copyLegacy(legacy string) error {
+	notCopied := fmt.Errorf("vault %s does not exist; a vault from an older version was found at %s, "+
+		"pass -vault %s to open it", e.vaultPath, legacy, legacy)
+	if !e.isTerminal() {
+		return notCopied
+	}
+
+	fmt.Fprintf(e.errOut, "Vault %s does not exist, but a vault from an older version was found at %s.\n"+
+		"Copy it there? The original is left in place. [y/N] ", e.vaultPath, legacy)
+	answer, err := e.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return notCopied
+	}
+
+	if err := vaults.CopyLegacy(legacy, e.vaultPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.errOut, "note: copied vault %s to %s\n", legacy, e.vaultPath)
+	return nil
+}
+
+func (e *env) open() (*db.DB, error) {
+	if e.db == nil {
+		database, err := db.NewDB(e.vaultPath)
+		if err != nil {
+			return nil, err
+		}
+		e.db = database
+	}
+	return e.db, nil
+}
+
+// unlock opens the vault and returns its key after asking for the master
+// password. Callers must clear the key with crypto.ClearBytes.
+func (e *env) unlock() ([]byte, error) {
+	database, err := e.openExisting()
+	if err != nil {
+		return nil, err
+	}
+	mk, err := database.GetMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	if mk == nil {
+		return nil, fmt.Errorf("vault %s is not initialised; run spms cli init", e.vaultPath)
+	}
+
+	// Fail before prompting while earlier failures still delay logins.
+	until, err := database.LoginDelay()
+	if err != nil {
+		return nil, err
+	}
+	if !until.IsZero() {
+		return nil, &db.LoginDelayError{Until: until}
+	}
+
+	password, err := e.readPassword("Master password: ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := database.UnlockVault(password)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := database.LastLoginInfo(); err == nil && info.FailedAttempts > 0 {
+		fmt.Fprintf(e.errOut, "warning: %d failed login attempt(s) since the last unlock, the latest at %s\n",
+			info.FailedAttempts, info.LastFailure.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	if _, err := database.PurgeExpired(); err != nil {
+		crypto.ClearBytes(key)
+		return nil, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	return key, nil
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors on
+// the error output.
+func (e *env) newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(e.errOut, "Usage: spms cli %s [options] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
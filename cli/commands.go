@@ -0,0 +1,867 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"spms/crypto"
+	"spms/db"
+	"spms/export"
+	"spms/importer"
+	"spms/otp"
+	"spms/utils"
+	"spms/vaults"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const hiddenValue = "********"
+
+// parseWithID parses flags given before or after a single entry ID.
+func parseWithID(fs *flag.FlagSet, args []string) (int, error) {
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 0, errUsage
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return 0, fmt.Errorf("invalid entry id %q", fs.Arg(0))
+	}
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return 0, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 0, errUsage
+	}
+	return id, nil
+}
+
+func parseNoArgs(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func runInit(e *env, args []string) error {
+	fs := e.newFlagSet("init", "")
+	cipherName := fs.String("cipher", crypto.DefaultAlgorithm.String(), "cipher for entry fields")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+
+	cipher, err := parseCipher(*cipherName)
+	if err != nil {
+		return err
+	}
+
+	database, err := e.open()
+	if err != nil {
+		return err
+	}
+	mk, err := database.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if mk != nil {
+		return fmt.Errorf("vault %s is already initialised", e.vaultPath)
+	}
+
+	password, err := e.readNewPassword("New master password: ")
+	if err != nil {
+		return err
+	}
+	if len(password) < 12 {
+		return errors.New("password must be at least 12 characters")
+	}
+
+	key, err := database.InitVault(password, cipher)
+	if err != nil {
+		return err
+	}
+	crypto.ClearBytes(key)
+	return e.done(fmt.Sprintf("Initialised vault %s", e.vaultPath))
+}
+
+func parseCipher(name string) (crypto.Algorithm, error) {
+	var names []string
+	for _, alg := range crypto.Algorithms() {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+		names = append(names, alg.String())
+	}
+	return 0, fmt.Errorf("unknown cipher %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+func runUnlock(e *env, args []string) error {
+	if err := parseNoArgs(e.newFlagSet("unlock", ""), args); err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	crypto.ClearBytes(key)
+	return e.done("Vault unlocked")
+}
+
+// done reports a successful command without output of its own.
+func (e *env) done(message string) error {
+	if e.json {
+		return e.printJSON(map[string]bool{"ok": true})
+	}
+	fmt.Fprintln(e.out, message)
+	return nil
+}
+
+func categoryNames(database *db.DB, key []byte) (map[int]string, error) {
+	categories, err := database.GetCategories(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.CategoryPaths(categories), nil
+}
+
+func categoryID(database *db.DB, key []byte, name string) (*int, error) {
+	if name == "" {
+		return nil, nil
+	}
+	categories, err := database.GetCategories(key)
+	if err != nil {
+		return nil, err
+	}
+	// Names are only unique among siblings, so a bare name must not match
+	// categories under different parents.
+	paths := db.CategoryPaths(categories)
+	var matches []int
+	for _, c := range categories {
+		if strings.EqualFold(paths[c.ID], name) {
+			id := c.ID
+			return &id, nil
+		}
+		if strings.EqualFold(c.Name, name) {
+			matches = append(matches, c.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown category %q", name)
+	case 1:
+		return &matches[0], nil
+	}
+	var candidates []string
+	for _, id := range matches {
+		candidates = append(candidates, paths[id])
+	}
+	sort.Strings(candidates)
+	return nil, fmt.Errorf("category %q is ambiguous; use its path (%s)", name, strings.Join(candidates, ", "))
+}
+
+func tagID(database *db.DB, key []byte, name string) (int, error) {
+	tags, err := database.GetTags(key)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range tags {
+		if strings.EqualFold(t.Name, name) {
+			return t.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tag %q", name)
+}
+
+func splitTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func validateTags(list string) error {
+	for _, tag := range splitTags(list) {
+		if _, err := db.NormalizeTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toJSON(entry db.PasswordEntry, categories map[int]string) entryJSON {
+	out := entryJSON{
+		ID:       entry.ID,
+		Website:  entry.Website,
+		Username: entry.Username,
+		Notes:    entry.Notes,
+		Favorite: entry.Favorite,
+	}
+	if entry.CategoryID != nil {
+		out.Category = categories[*entry.CategoryID]
+	}
+	return out
+}
+
+func runList(e *env, args []string) error {
+	fs := e.newFlagSet("ls", "[query]")
+	category := fs.String("category", "", "only list entries in this category")
+	tag := fs.String("tag", "", "only list entries with this tag")
+	sortName := fs.String("sort", db.SortByWebsite.String(), "sort order: website, favorites-first, recently-used or frequently-used")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	order, err := db.ParseSortOrder(*sortName)
+	if err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	filter := db.EntryFilter{Query: fs.Arg(0), Sort: order}
+	if *category != "" {
+		id, err := categoryID(e.db, key, *category)
+		if err != nil {
+			return err
+		}
+		filter.Categories = []int{*id}
+	}
+	if *tag != "" {
+		id, err := tagID(e.db, key, *tag)
+		if err != nil {
+			return err
+		}
+		filter.Tags = []int{id}
+	}
+	entries, err := e.db.SearchEntries(key, filter)
+	if err != nil {
+		return err
+	}
+	categories, err := categoryNames(e.db, key)
+	if err != nil {
+		return err
+	}
+
+	var out []entryJSON
+	for _, entry := range entries {
+		item := toJSON(entry, categories)
+		item.Notes = ""
+		out = append(out, item)
+	}
+	return e.printEntries(out)
+}
+
+func runShow(e *env, args []string) error {
+	fs := e.newFlagSet("show", "<id>")
+	reveal := fs.Bool("reveal", false, "include the password, secret fields and one-time code")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	entry, err := e.db.GetEntry(key, id)
+	if err != nil {
+		return err
+	}
+	categories, err := categoryNames(e.db, key)
+	if err != nil {
+		return err
+	}
+	out := toJSON(entry, categories)
+
+	tags, err := e.db.GetEntryTags(key, id)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		out.Tags = append(out.Tags, t.Name)
+	}
+
+	fields, err := e.db.GetFields(key, id)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		value := f.Value
+		if f.Type.IsSecret() && !*reveal {
+			value = hiddenValue
+		}
+		out.Fields = append(out.Fields, fieldJSON{Name: f.Name, Type: f.Type, Value: value})
+	}
+
+	if *reveal {
+		password, err := e.db.DecryptPassword(key, entry)
+		if err != nil {
+			return err
+		}
+		out.Password = string(password)
+		crypto.ClearBytes(password)
+		if err := e.db.MarkUsed(id); err != nil {
+			return err
+		}
+
+		uri, err := e.db.DecryptTOTP(key, entry)
+		if err != nil {
+			return err
+		}
+		if uri != "" {
+			otpKey, err := otp.Parse(uri)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			code, err := otpKey.Code(now)
+			if err != nil {
+				return err
+			}
+			out.TOTP = &totpJSON{Code: code, Remaining: int(otpKey.Remaining(now).Seconds())}
+		}
+	}
+	return e.printEntry(out)
+}
+
+// entryPassword returns a generated password when length is positive and
+// asks for one otherwise.
+func (e *env) entryPassword(length int) (string, error) {
+	if length > 0 {
+		return utils.GeneratePassword(utils.GeneratorConfig{
+			Length:     length,
+			UseLower:   true,
+			UseUpper:   true,
+			UseDigits:  true,
+			UseSymbols: true,
+		})
+	}
+	return e.readNewPassword("Entry password: ")
+}
+
+func runAdd(e *env, args []string) error {
+	fs := e.newFlagSet("add", "")
+	website := fs.String("website", "", "website of the entry (required)")
+	username := fs.String("username", "", "username of the entry (required)")
+	notes := fs.String("notes", "", "notes")
+	category := fs.String("category", "", "category name")
+	totp := fs.String("totp", "", "otpauth:// URI or base32 secret")
+	tags := fs.String("tags", "", "comma-separated tags")
+	generate := fs.Int("generate", 0, "generate a password of this length instead of asking for one")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+	if *website == "" || *username == "" {
+		fs.Usage()
+		return errUsage
+	}
+	if *totp != "" {
+		if _, err := otp.Parse(*totp); err != nil {
+			return err
+		}
+	}
+	if err := validateTags(*tags); err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	categoryID, err := categoryID(e.db, key, *category)
+	if err != nil {
+		return err
+	}
+	password, err := e.entryPassword(*generate)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = e.db.WithTx(func(tx *db.Tx) error {
+		var err error
+		id, err = tx.AddEntry(key, *website, *username, []byte(password), *notes, categoryID)
+		if err != nil {
+			return err
+		}
+		if err := tx.SetTOTP(key, id, *totp); err != nil {
+			return err
+		}
+		return tx.SetEntryTags(key, id, splitTags(*tags))
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.json {
+		return e.printJSON(map[string]int{"id": id})
+	}
+	fmt.Fprintf(e.out, "Added entry %d\n", id)
+	return nil
+}
+
+func runEdit(e *env, args []string) error {
+	fs := e.newFlagSet("edit", "<id>")
+	website := fs.String("website", "", "new website")
+	username := fs.String("username", "", "new username")
+	notes := fs.String("notes", "", "new notes")
+	category := fs.String("category", "", "new category name, empty for none")
+	totp := fs.String("totp", "", "new otpauth:// URI or base32 secret, empty to remove")
+	tags := fs.String("tags", "", "new comma-separated tags, empty to remove all")
+	changePassword := fs.Bool("password", false, "ask for a new password")
+	generate := fs.Int("generate", 0, "generate a new password of this length")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if err := validateTags(*tags); err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	entry, err := e.db.GetEntry(key, id)
+	if err != nil {
+		return err
+	}
+	if set["website"] {
+		entry.Website = *website
+	}
+	if set["username"] {
+		entry.Username = *username
+	}
+	if set["notes"] {
+		entry.Notes = *notes
+	}
+	if set["category"] {
+		entry.CategoryID, err = categoryID(e.db, key, *category)
+		if err != nil {
+			return err
+		}
+	}
+
+	var password []byte
+	if *changePassword || *generate > 0 {
+		newPassword, err := e.entryPassword(*generate)
+		if err != nil {
+			return err
+		}
+		password = []byte(newPassword)
+	} else {
+		password, err = e.db.DecryptPassword(key, entry)
+		if err != nil {
+			return err
+		}
+	}
+	defer crypto.ClearBytes(password)
+
+	err = e.db.WithTx(func(tx *db.Tx) error {
+		if err := tx.UpdateEntry(key, id, entry.Website, entry.Username, password, entry.Notes, entry.CategoryID); err != nil {
+			return err
+		}
+		if set["totp"] {
+			if err := tx.SetTOTP(key, id, *totp); err != nil {
+				return err
+			}
+		}
+		if set["tags"] {
+			return tx.SetEntryTags(key, id, splitTags(*tags))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.done(fmt.Sprintf("Updated entry %d", id))
+}
+
+func runRemove(e *env, args []string) error {
+	fs := e.newFlagSet("rm", "<id>")
+	purge := fs.Bool("purge", false, "delete permanently instead of moving to the trash")
+	id, err := parseWithID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	if _, err := e.db.GetEntry(key, id); err != nil {
+		return err
+	}
+	if err := e.db.DeleteEntry(id); err != nil {
+		return err
+	}
+	if *purge {
+		if err := e.db.PurgeEntry(id); err != nil {
+			return err
+		}
+		return e.done(fmt.Sprintf("Deleted entry %d", id))
+	}
+	return e.done(fmt.Sprintf("Moved entry %d to the trash", id))
+}
+
+func runGenerate(e *env, args []string) error {
+	fs := e.newFlagSet("generate", "")
+	config := utils.GeneratorConfig{}
+	fs.IntVar(&config.Length, "length", 16, "password length")
+	fs.BoolVar(&config.UseUpper, "upper", true, "include uppercase letters")
+	fs.BoolVar(&config.UseLower, "lower", true, "include lowercase letters")
+	fs.BoolVar(&config.UseDigits, "digits", true, "include digits")
+	fs.BoolVar(&config.UseSymbols, "symbols", false, "include special characters")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+
+	password, err := utils.GeneratePassword(config)
+	if err != nil {
+		return err
+	}
+	if e.json {
+		return e.printJSON(map[string]any{
+			"password": password,
+			"strength": utils.EvaluatePasswordStrength(password),
+		})
+	}
+	fmt.Fprintln(e.out, password)
+	return nil
+}
+
+func runPasswd(e *env, args []string) error {
+	fs := e.newFlagSet("passwd", "")
+	rotate := fs.Bool("rotate", false, "also re-encrypt all entries with a new vault key")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+
+	database, err := e.openExisting()
+	if err != nil {
+		return err
+	}
+	oldPassword, err := e.readPassword("Current master password: ")
+	if err != nil {
+		return err
+	}
+	newPassword, err := e.readNewPassword("New master password: ")
+	if err != nil {
+		return err
+	}
+	if len(newPassword) < 16 {
+		return errors.New("new password must be at least 16 characters")
+	}
+
+	if *rotate {
+		key, err := database.RotateVaultKey(oldPassword, newPassword)
+		if err != nil {
+			return err
+		}
+		crypto.ClearBytes(key)
+	} else if err := database.ChangeMasterPassword(oldPassword, newPassword); err != nil {
+		return err
+	}
+	return e.done("Master password changed")
+}
+
+func runCipher(e *env, args []string) error {
+	fs := e.newFlagSet("cipher", "[name]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	database, err := e.openExisting()
+	if err != nil {
+		return err
+	}
+	mk, err := database.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if mk == nil {
+		return fmt.Errorf("vault %s is not initialised; run spms cli init", e.vaultPath)
+	}
+
+	if fs.NArg() == 0 {
+		if e.json {
+			return e.printJSON(map[string]string{"cipher": mk.Cipher.String()})
+		}
+		fmt.Fprintln(e.out, mk.Cipher)
+		return nil
+	}
+
+	cipher, err := parseCipher(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if cipher == mk.Cipher {
+		return e.done(fmt.Sprintf("Entries are already encrypted with %s", cipher))
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	if err := database.ChangeCipher(key, cipher); err != nil {
+		return err
+	}
+	return e.done(fmt.Sprintf("Re-encrypted all entries with %s", cipher))
+}
+
+func runExport(e *env, args []string) error {
+	fs := e.newFlagSet("export", "")
+	format := fs.String("format", "json", "export format: json or csv")
+	output := fs.String("o", "", "write to this file instead of standard output")
+	plaintext := fs.Bool("plaintext", false, "do not encrypt the export")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+	content := export.Content(*format)
+	if content != export.ContentJSON && content != export.ContentCSV {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	var passphrase string
+	if !*plaintext {
+		passphrase, err = e.readNewPassword("Export passphrase: ")
+		if err != nil {
+			return err
+		}
+		if len(passphrase) < export.MinPassphraseLength {
+			return fmt.Errorf("export passphrase must be at least %d characters", export.MinPassphraseLength)
+		}
+	}
+
+	v, err := export.Collect(e.db, key)
+	if err != nil {
+		return err
+	}
+	plain, err := export.Marshal(v, content)
+	defer crypto.ClearBytes(plain)
+	if err != nil {
+		return err
+	}
+	data := plain
+	if !*plaintext {
+		if data, err = export.Encrypt(plain, content, passphrase); err != nil {
+			return err
+		}
+	}
+
+	if *output == "" {
+		_, err := e.out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		return err
+	}
+	return e.done(fmt.Sprintf("Exported %d entries to %s", len(v.Entries), *output))
+}
+
+func runImport(e *env, args []string) error {
+	fs := e.newFlagSet("import", "FILE")
+	formatName := fs.String("format", "", "export format: bitwarden, keepass, 1password, lastpass, chrome or spms")
+	dryRun := fs.Bool("dry-run", false, "list the entries that would be imported without adding them")
+	keepDuplicates := fs.Bool("duplicates", false, "also import entries that are already in the vault")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	path := fs.Arg(0)
+	if err := parseNoArgs(fs, fs.Args()[1:]); err != nil {
+		return err
+	}
+	if *formatName == "" {
+		return errors.New("-format is required")
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	res, err := importer.Parse(format, bytes.NewReader(data))
+	if errors.Is(err, importer.ErrPassphraseRequired) {
+		var passphrase string
+		passphrase, err = e.readPassword("Export passphrase: ")
+		if err != nil {
+			return err
+		}
+		res, err = importer.ParseWithPassphrase(format, bytes.NewReader(data), passphrase)
+	}
+	if err != nil {
+		return err
+	}
+
+	key, err := e.unlock()
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(key)
+
+	duplicates, err := importer.FindDuplicates(e.db, key, res.Records)
+	if err != nil {
+		return err
+	}
+	var records []importer.Record
+	var skippedDuplicates int
+	for i, r := range res.Records {
+		if duplicates[i] && !*keepDuplicates {
+			skippedDuplicates++
+			continue
+		}
+		records = append(records, r)
+	}
+
+	summary := importSummary{
+		Duplicates: skippedDuplicates,
+		Skipped:    problemStrings(res.Skipped),
+		Warnings:   problemStrings(res.Warnings),
+	}
+	if *dryRun {
+		summary.Imported = len(records)
+		if !e.json {
+			w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ROW\tWEBSITE\tUSERNAME\tCATEGORY")
+			for _, r := range records {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Row, r.Website(), r.Username, r.CategoryPath())
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	} else {
+		added, failed, err := importer.Import(e.db, key, records)
+		summary.Imported = added
+		summary.Skipped = append(summary.Skipped, problemStrings(failed)...)
+		if err != nil {
+			return fmt.Errorf("import stopped after %d entries: %w", added, err)
+		}
+	}
+	return e.printImportSummary(summary, *dryRun)
+}
+
+// importSummary is the result of an import, also used as its JSON output.
+type importSummary struct {
+	Imported   int      `json:"imported"`
+	Duplicates int      `json:"duplicates"`
+	Skipped    []string `json:"skipped"`
+	Warnings   []string `json:"warnings"`
+}
+
+func (e *env) printImportSummary(s importSummary, dryRun bool) error {
+	if e.json {
+		if s.Skipped == nil {
+			s.Skipped = []string{}
+		}
+		if s.Warnings == nil {
+			s.Warnings = []string{}
+		}
+		return e.printJSON(s)
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(e.out, "%s %d entries", verb, s.Imported)
+	if s.Duplicates > 0 {
+		fmt.Fprintf(e.out, ", %d duplicates left out", s.Duplicates)
+	}
+	fmt.Fprintln(e.out)
+	if len(s.Skipped) > 0 {
+		fmt.Fprintf(e.errOut, "Skipped %d rows:\n", len(s.Skipped))
+		for _, p := range s.Skipped {
+			fmt.Fprintln(e.errOut, "  "+p)
+		}
+	}
+	if len(s.Warnings) > 0 {
+		fmt.Fprintln(e.errOut, "Warnings:")
+		for _, p := range s.Warnings {
+			fmt.Fprintln(e.errOut, "  "+p)
+		}
+	}
+	return nil
+}
+
+func problemStrings(problems []importer.Problem) []string {
+	var s []string
+	for _, p := range problems {
+		s = append(s, p.String())
+	}
+	return s
+}
+
+func runVaults(e *env, args []string) error {
+	if err := parseNoArgs(e.newFlagSet("vaults", ""), args); err != nil {
+		return err
+	}
+
+	names, err := vaults.List()
+	if err != nil {
+		return err
+	}
+	if e.json {
+		if names == nil {
+			names = []string{}
+		}
+		return e.printJSON(names)
+	}
+	for _, name := range names {
+		fmt.Fprintln(e.out, name)
+	}
+	return nil
+}
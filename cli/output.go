@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"spms/db"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type entryJSON struct {
+	ID       int         `json:"id"`
+	Website  string      `json:"website"`
+	Username string      `json:"username"`
+	Category string      `json:"category,omitempty"`
+	Tags     []string    `json:"tags,omitempty"`
+	Favorite bool        `json:"favorite,omitempty"`
+	Notes    string      `json:"notes,omitempty"`
+	Password string      `json:"password,omitempty"`
+	TOTP     *totpJSON   `json:"totp,omitempty"`
+	Fields   []fieldJSON `json:"fields,omitempty"`
+}
+
+type totpJSON struct {
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining"` // seconds
+}
+
+type fieldJSON struct {
+	Name  string       `json:"name"`
+	Type  db.FieldType `json:"type"`
+	Value string       `json:"value"`
+}
+
+func (e *env) printJSON(v any) error {
+	enc := json.NewEncoder(e.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (e *env) printEntries(entries []entryJSON) error {
+	if e.json {
+		if entries == nil {
+			entries = []entryJSON{}
+		}
+		return e.printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tWEBSITE\tUSERNAME\tCATEGORY")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", entry.ID, entry.Website, entry.Username, entry.Category)
+	}
+	return w.Flush()
+}
+
+func (e *env) printEntry(entry entryJSON) error {
+	if e.json {
+		return e.printJSON(entry)
+	}
+
+	w := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", entry.ID)
+	fmt.Fprintf(w, "Website:\t%s\n", entry.Website)
+	fmt.Fprintf(w, "Username:\t%s\n", entry.Username)
+	if entry.Category != "" {
+		fmt.Fprintf(w, "Category:\t%s\n", entry.Category)
+	}
+	if len(entry.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(entry.Tags, ", "))
+	}
+	if entry.Password != "" {
+		fmt.Fprintf(w, "Password:\t%s\n", entry.Password)
+	}
+	if entry.TOTP != nil {
+		fmt.Fprintf(w, "One-time code:\t%s (%s left)\n", entry.TOTP.Code, time.Duration(entry.TOTP.Remaining)*time.Second)
+	}
+	for _, f := range entry.Fields {
+		fmt.Fprintf(w, "%s:\t%s\n", f.Name, f.Value)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if entry.Notes != "" {
+		fmt.Fprintf(e.out, "\n%s\n", entry.Notes)
+	}
+	return nil
+}
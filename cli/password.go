@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func (e *env) isTerminal() bool {
+	return term.IsTerminal(int(e.stdin.Fd()))
+}
+
+// readPassword prompts for a password on the terminal without echo. When
+// stdin is not a terminal one line is read from it instead, so passwords
+// can be piped in.
+func (e *env) readPassword(prompt string) (string, error) {
+	if e.isTerminal() {
+		fmt.Fprint(e.errOut, prompt)
+		password, err := term.ReadPassword(int(e.stdin.Fd()))
+		fmt.Fprintln(e.errOut)
+		if err != nil {
+			return "", err
+		}
+		return string(password), nil
+	}
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no password on stdin")
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassword asks for a new password, with confirmation when reading
+// from a terminal.
+func (e *env) readNewPassword(prompt string) (string, error) {
+	password, err := e.readPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	if e.isTerminal() {
+		confirm, err := e.readPassword("Confirm: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != password {
+			return "", errors.New("passwords don't match")
+		}
+	}
+	return password, nil
+}
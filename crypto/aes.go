@@ -4,54 +4,35 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"errors"
-	"io"
 )
 
 // Encrypt encrypts data using AES-GCM with the provided key.
 func Encrypt(data, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+	return EncryptWithAD(data, key, nil)
 }
 
-// Decrypt decrypts data using AES-GCM with the provided key.
+// Decrypt decrypts data with the provided key. It accepts both enveloped
+// and legacy ciphertexts without associated data.
 func Decrypt(ciphertext, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	if hasEnvelope(ciphertext) {
+		if plaintext, err := DecryptWithAD(ciphertext, key, nil); err == nil {
+			return plaintext, nil
+		}
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
+	return openAEAD(gcm, ciphertext, nil)
+}
 
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return nil, errors.New("ciphertext too short")
-	}
-
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return plaintext, nil
+	return cipher.NewGCM(block)
 }
 
 // GenerateSecureKey generates a cryptographically secure random key.
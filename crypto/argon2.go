@@ -15,23 +15,63 @@ type Argon2Params struct {
 	KeyLength   uint32
 }
 
-// DefaultParams sets secure defaults for Argon2.
+// DefaultParams sets secure defaults for Argon2. Vaults using weaker
+// parameters are offered an upgrade after unlocking.
 var DefaultParams = Argon2Params{
-	Memory:      64 * 1024, // 64 MB
-	Iterations:  3,
+	Memory:      128 * 1024, // 128 MB
+	Iterations:  4,
 	Parallelism: 4,
 	SaltLength:  16,
 	KeyLength:   32, // 256-bit key
 }
 
-// DeriveKey derives a key from a password using Argon2id.
+// LegacyParams are the parameters used by vaults created before the
+// parameters were stored alongside the salt. They are the defaults of that
+// time and must not change.
+var LegacyParams = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// WeakerThan reports whether p is cheaper to brute-force than other.
+func (p Argon2Params) WeakerThan(other Argon2Params) bool {
+	return p.Memory < other.Memory ||
+		p.Iterations < other.Iterations ||
+		p.Parallelism < other.Parallelism ||
+		p.KeyLength < other.KeyLength
+}
+
+// Validate checks that the parameters can be used for key derivation.
+func (p Argon2Params) Validate() error {
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return errors.New("invalid argon2 parameters")
+	}
+	if p.KeyLength != 16 && p.KeyLength != 24 && p.KeyLength != 32 {
+		return errors.New("invalid argon2 key length")
+	}
+	return nil
+}
+
+// DeriveKey derives a key from a password using Argon2id and DefaultParams.
 func DeriveKey(password string, salt []byte) ([]byte, error) {
+	return DeriveKeyWithParams(password, salt, DefaultParams)
+}
+
+// DeriveKeyWithParams derives a key from a password using Argon2id and the
+// given parameters.
+func DeriveKeyWithParams(password string, salt []byte, params Argon2Params) ([]byte, error) {
 	if password == "" {
 		return nil, errors.New("password cannot be empty")
 	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 	return argon2.IDKey([]byte(password), salt,
-		DefaultParams.Iterations, DefaultParams.Memory,
-		DefaultParams.Parallelism, DefaultParams.KeyLength), nil
+		params.Iterations, params.Memory,
+		params.Parallelism, params.KeyLength), nil
 }
 
 // GetEncryptedCheck encrypts a known value for master key verification.
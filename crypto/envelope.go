@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm identifies the AEAD used for a ciphertext.
+type Algorithm byte
+
+const (
+	AES256GCM         Algorithm = 1
+	XChaCha20Poly1305 Algorithm = 2
+)
+
+// DefaultAlgorithm is used when no algorithm is selected.
+const DefaultAlgorithm = AES256GCM
+
+// Envelope layout:
+//
+//	v1: 'S' 'P' 0x01 | nonce | ciphertext          (AES-256-GCM)
+//	v2: 'S' 'P' 0x02 | algorithm | nonce | ciphertext
+//
+// The header is authenticated as part of the associated data. Blobs written
+// before the envelope existed are a bare AES-GCM nonce||ciphertext.
+var envelopeMagic = []byte{'S', 'P'}
+
+const (
+	envelopeV1          = 1
+	envelopeV2          = 2
+	envelopeHeaderV1Len = 3
+	envelopeHeaderV2Len = 4
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case AES256GCM:
+		return "AES-256-GCM"
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("Algorithm(%d)", byte(a))
+}
+
+// Algorithms lists the selectable algorithms.
+func Algorithms() []Algorithm {
+	return []Algorithm{AES256GCM, XChaCha20Poly1305}
+}
+
+func (a Algorithm) aead(key []byte) (cipher.AEAD, error) {
+	switch a {
+	case AES256GCM:
+		return newGCM(key)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("unsupported algorithm %d", byte(a))
+}
+
+// EncryptWithAD encrypts data with the default algorithm, authenticating ad
+// alongside the ciphertext. The same ad must be supplied to DecryptWithAD.
+func EncryptWithAD(data, key, ad []byte) ([]byte, error) {
+	return EncryptWithAlgorithm(DefaultAlgorithm, data, key, ad)
+}
+
+// EncryptWithAlgorithm encrypts data with alg and wraps it in an envelope.
+func EncryptWithAlgorithm(alg Algorithm, data, key, ad []byte) ([]byte, error) {
+	aead, err := alg.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte{envelopeMagic[0], envelopeMagic[1], envelopeV2, byte(alg)}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, envelopeAD(header, ad)), nil
+}
+
+// DecryptWithAD decrypts an envelope, dispatching on its header. Legacy
+// ciphertexts without a header are rejected.
+func DecryptWithAD(ciphertext, key, ad []byte) ([]byte, error) {
+	if !hasEnvelope(ciphertext) {
+		return nil, errors.New("ciphertext has no envelope header")
+	}
+
+	var (
+		alg       Algorithm
+		headerLen int
+	)
+	switch ciphertext[len(envelopeMagic)] {
+	case envelopeV1:
+		alg, headerLen = AES256GCM, envelopeHeaderV1Len
+	case envelopeV2:
+		if len(ciphertext) <= envelopeHeaderV2Len {
+			return nil, errors.New("ciphertext too short")
+		}
+		alg, headerLen = Algorithm(ciphertext[envelopeHeaderV1Len]), envelopeHeaderV2Len
+	default:
+		return nil, errors.New("unsupported ciphertext version")
+	}
+
+	aead, err := alg.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	header, body := ciphertext[:headerLen], ciphertext[headerLen:]
+	return openAEAD(aead, body, envelopeAD(header, ad))
+}
+
+func hasEnvelope(ciphertext []byte) bool {
+	return len(ciphertext) > envelopeHeaderV1Len &&
+		ciphertext[0] == envelopeMagic[0] &&
+		ciphertext[1] == envelopeMagic[1]
+}
+
+// envelopeAD authenticates the header together with the caller's data so
+// the version and algorithm cannot be altered.
+func envelopeAD(header, ad []byte) []byte {
+	out := make([]byte, 0, len(header)+len(ad))
+	out = append(out, header...)
+	return append(out, ad...)
+}
+
+func openAEAD(aead cipher.AEAD, ciphertext, ad []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,28 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveSubkey derives an independent key for purpose from the vault key.
+func DeriveSubkey(key []byte, purpose string) ([]byte, error) {
+	subkey := make([]byte, 32)
+	r := hkdf.New(sha256.New, key, nil, []byte(purpose))
+	if _, err := io.ReadFull(r, subkey); err != nil {
+		return nil, err
+	}
+	return subkey, nil
+}
+
+// BlindIndex returns a keyed hash of value that allows equality lookups
+// without storing the value itself. Matching is case-insensitive.
+func BlindIndex(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,19 @@
+package crypto
+
+// VaultKeyLength is the size of the random key that encrypts vault data.
+const VaultKeyLength = 32
+
+// GenerateVaultKey creates a new random data-encryption key.
+func GenerateVaultKey() ([]byte, error) {
+	return GenerateSecureKey(VaultKeyLength)
+}
+
+// WrapKey encrypts a vault key with a key-encryption key.
+func WrapKey(vaultKey, kek []byte) ([]byte, error) {
+	return Encrypt(vaultKey, kek)
+}
+
+// UnwrapKey decrypts a vault key previously wrapped with WrapKey.
+func UnwrapKey(wrapped, kek []byte) ([]byte, error) {
+	return Decrypt(wrapped, kek)
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package crypto
+
+func lockMemory(b []byte) error {
+	return nil
+}
+
+func unlockMemory(b []byte) error {
+	return nil
+}
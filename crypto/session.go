@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrLocked is returned when the session key is requested while locked.
+var ErrLocked = errors.New("vault is locked")
+
+// Session owns the unlocked master key for the lifetime of an unlock.
+type Session struct {
+	mu  sync.RWMutex
+	key []byte
+}
+
+// NewSession returns a locked session.
+func NewSession() *Session {
+	return &Session{}
+}
+
+// Unlock copies key into locked memory and wipes the caller's copy.
+func (s *Session) Unlock(key []byte) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+
+	buf := make([]byte, len(key))
+	copy(buf, key)
+	ClearBytes(key)
+	// Locking memory is best effort; the key is still wiped on Lock.
+	_ = lockMemory(buf)
+
+	s.mu.Lock()
+	old := s.key
+	s.key = buf
+	s.mu.Unlock()
+
+	wipe(old)
+	return nil
+}
+
+// Lock wipes the session key. It is safe to call on a locked session.
+func (s *Session) Lock() {
+	s.mu.Lock()
+	old := s.key
+	s.key = nil
+	s.mu.Unlock()
+
+	wipe(old)
+}
+
+// IsLocked reports whether the session currently holds no key.
+func (s *Session) IsLocked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.key == nil
+}
+
+// WithKey calls fn with the session key. The slice must not be retained
+// after fn returns.
+func (s *Session) WithKey(fn func(key []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.key == nil {
+		return ErrLocked
+	}
+	return fn(s.key)
+}
+
+func wipe(b []byte) {
+	if b == nil {
+		return
+	}
+	ClearBytes(b)
+	_ = unlockMemory(b)
+}
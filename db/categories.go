@@ -0,0 +1,411 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"spms/crypto"
+	"strings"
+)
+
+type Category struct {
+	ID       int
+	Name     string
+	ParentID *int   // nil for top-level categories
+	Color    string // "#rrggbb", or empty for the default
+	Icon     string // one of CategoryIcons, or empty for the default
+}
+
+// CategoryIcons lists the icon names a category may use.
+var CategoryIcons = []string{"folder", "home", "account", "mail", "computer", "storage", "settings", "info"}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (c *Category) validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("category name cannot be empty")
+	}
+	if c.Color != "" && !colorPattern.MatchString(c.Color) {
+		return fmt.Errorf("invalid color %q", c.Color)
+	}
+	if c.Icon != "" && !slices.Contains(CategoryIcons, c.Icon) {
+		return fmt.Errorf("unknown icon %q", c.Icon)
+	}
+	return nil
+}
+
+const categoryIndexPurpose = "spms category index"
+
+// AddCategory stores a new top-level category.
+func (db *DB) AddCategory(key []byte, name string) error {
+	_, err := db.CreateCategory(key, Category{Name: name})
+	return err
+}
+
+// CreateCategory stores a new category and returns its ID.
+func (db *DB) CreateCategory(key []byte, c Category) (int, error) {
+	var id int
+	err := db.WithTx(func(tx *Tx) error {
+		var err error
+		id, err = tx.CreateCategory(key, c)
+		return err
+	})
+	return id, err
+}
+
+func createCategory(q querier, key []byte, c Category) (int, error) {
+	if err := c.validate(); err != nil {
+		return 0, err
+	}
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := q.Exec("INSERT INTO categories (parent_id) VALUES (?)", c.ParentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create category: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	c.ID = int(id)
+	if err := writeCategory(q, keys, c); err != nil {
+		return 0, err
+	}
+	return c.ID, nil
+}
+
+// UpdateCategory renames, moves or restyles a category.
+func (db *DB) UpdateCategory(key []byte, c Category) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	return db.WithTx(func(tx *Tx) error {
+		keys, err := loadEntryKeys(tx.tx, key)
+		if err != nil {
+			return err
+		}
+		var exists bool
+		if err := tx.tx.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?)", c.ID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("category not found")
+		}
+
+		if c.ParentID != nil {
+			categories, err := getCategories(tx.tx, keys)
+			if err != nil {
+				return err
+			}
+			if isDescendant(categories, *c.ParentID, c.ID) {
+				return errors.New("a category cannot be moved into itself")
+			}
+		}
+		return writeCategory(tx.tx, keys, c)
+	})
+}
+
+// DeleteCategory removes a category. Its entries move to reassignTo, or
+// become uncategorised when it is nil, and its subcategories move up to its
+// parent.
+func (db *DB) DeleteCategory(id int, reassignTo *int) error {
+	if reassignTo != nil && *reassignTo == id {
+		return errors.New("cannot reassign entries to the deleted category")
+	}
+
+	return db.WithTx(func(tx *Tx) error {
+		return deleteCategory(tx.tx, id, reassignTo)
+	})
+}
+
+func deleteCategory(q querier, id int, reassignTo *int) error {
+	var parentID *int
+	err := q.QueryRow("SELECT parent_id FROM categories WHERE id = ?", id).Scan(&parentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("category not found")
+		}
+		return err
+	}
+
+	if _, err := q.Exec("UPDATE passwords SET category_id = ? WHERE category_id = ?", reassignTo, id); err != nil {
+		return err
+	}
+	if _, err := q.Exec("UPDATE categories SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return err
+	}
+	_, err = q.Exec("DELETE FROM categories WHERE id = ?", id)
+	return err
+}
+
+// GetCategories returns every category with its name and style decrypted,
+// ordered by name.
+func (db *DB) GetCategories(key []byte) ([]Category, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return getCategories(db.conn, keys)
+}
+
+func getCategories(q querier, keys entryKeys) ([]Category, error) {
+	rows, err := queryCategoryRows(q)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]Category, 0, len(rows))
+	for _, r := range rows {
+		c, err := r.decrypt(keys)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return strings.ToLower(categories[i].Name) < strings.ToLower(categories[j].Name)
+	})
+	return categories, nil
+}
+
+// categoryAD binds a category field to its vault and row, in a namespace
+// separate from entries and tags.
+func (k entryKeys) categoryAD(id int, field string) []byte {
+	return []byte(fmt.Sprintf("%x/category/%d/%s", k.vaultID, id, field))
+}
+
+func (k entryKeys) categoryIndex(name string) ([]byte, error) {
+	indexKey, err := crypto.DeriveSubkey(k.key, categoryIndexPurpose)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(indexKey)
+	return crypto.BlindIndex(indexKey, name), nil
+}
+
+// writeCategory encrypts c into its row and clears any plaintext columns.
+// Names are unique among siblings, compared case-insensitively through
+// their blind index.
+func writeCategory(q querier, keys entryKeys, c Category) error {
+	index, err := keys.categoryIndex(c.Name)
+	if err != nil {
+		return err
+	}
+	var existing int
+	err = q.QueryRow("SELECT id FROM categories WHERE parent_id IS ? AND name_index = ? AND id != ?", c.ParentID, index, c.ID).Scan(&existing)
+	if err == nil {
+		return fmt.Errorf("category %q already exists", c.Name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var encrypted [3][]byte
+	for i, field := range []struct{ name, value string }{
+		{"name", c.Name}, {"color", c.Color}, {"icon", c.Icon},
+	} {
+		encrypted[i], err = crypto.EncryptWithAlgorithm(keys.cipher, []byte(field.value), keys.key, keys.categoryAD(c.ID, field.name))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = q.Exec(
+		`UPDATE categories SET name = NULL, color = '', icon = '',
+			encrypted_name = ?, encrypted_color = ?, encrypted_icon = ?, name_index = ?, parent_id = ?
+		WHERE id = ?`,
+		encrypted[0], encrypted[1], encrypted[2], index, c.ParentID, c.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save category: %w", err)
+	}
+	return nil
+}
+
+// categoryRow is a categories row as stored. Categories created before
+// they were encrypted keep their plaintext columns until the vault is next
+// unlocked.
+type categoryRow struct {
+	id             int
+	parentID       *int
+	name           sql.NullString
+	color          string
+	icon           string
+	encryptedName  []byte
+	encryptedColor []byte
+	encryptedIcon  []byte
+}
+
+func (r categoryRow) isLegacy() bool {
+	return len(r.encryptedName) == 0
+}
+
+func (r categoryRow) decrypt(keys entryKeys) (Category, error) {
+	c := Category{ID: r.id, ParentID: r.parentID}
+	if r.isLegacy() {
+		c.Name, c.Color, c.Icon = r.name.String, r.color, r.icon
+		return c, nil
+	}
+
+	for _, field := range []struct {
+		name       string
+		ciphertext []byte
+		value      *string
+	}{
+		{"name", r.encryptedName, &c.Name},
+		{"color", r.encryptedColor, &c.Color},
+		{"icon", r.encryptedIcon, &c.Icon},
+	} {
+		plaintext, err := crypto.DecryptWithAD(field.ciphertext, keys.key, keys.categoryAD(r.id, field.name))
+		if err != nil {
+			return c, fmt.Errorf("failed to decrypt category %d: %w", r.id, err)
+		}
+		*field.value = string(plaintext)
+	}
+	return c, nil
+}
+
+func queryCategoryRows(q querier) ([]categoryRow, error) {
+	rows, err := q.Query(
+		`SELECT id, parent_id, name, color, icon, encrypted_name, encrypted_color, encrypted_icon
+		FROM categories ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	var result []categoryRow
+	for rows.Next() {
+		var r categoryRow
+		if err := rows.Scan(&r.id, &r.parentID, &r.name, &r.color, &r.icon,
+			&r.encryptedName, &r.encryptedColor, &r.encryptedIcon); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// rewriteCategories re-encrypts every category under newKeys. With
+// legacyOnly, only categories still stored in plaintext are converted.
+func rewriteCategories(q querier, oldKeys, newKeys entryKeys, legacyOnly bool) error {
+	rows, err := queryCategoryRows(q)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[int]int)
+	for _, r := range parentsFirst(rows) {
+		if legacyOnly && !r.isLegacy() {
+			continue
+		}
+		c, err := r.decrypt(oldKeys)
+		if err != nil {
+			return err
+		}
+		if r.parentID != nil {
+			if into, ok := merged[*r.parentID]; ok {
+				c.ParentID = &into
+			}
+		}
+
+		// Plaintext names were unique among siblings only when compared
+		// case-sensitively, so two of them may share a blind index. Merge
+		// those into the sibling that was converted first.
+		index, err := newKeys.categoryIndex(c.Name)
+		if err != nil {
+			return err
+		}
+		var existing int
+		err = q.QueryRow("SELECT id FROM categories WHERE parent_id IS ? AND name_index = ? AND id != ?", c.ParentID, index, c.ID).Scan(&existing)
+		if err == nil {
+			if _, err := q.Exec("UPDATE categories SET parent_id = ? WHERE parent_id = ?", existing, c.ID); err != nil {
+				return err
+			}
+			if err := deleteCategory(q, c.ID, &existing); err != nil {
+				return err
+			}
+			merged[c.ID] = existing
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := writeCategory(q, newKeys, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parentsFirst orders rows so that every category follows its parent.
+func parentsFirst(rows []categoryRow) []categoryRow {
+	byParent := make(map[int][]categoryRow)
+	known := make(map[int]bool, len(rows))
+	for _, r := range rows {
+		known[r.id] = true
+	}
+	var ordered []categoryRow
+	for _, r := range rows {
+		if r.parentID == nil || !known[*r.parentID] {
+			ordered = append(ordered, r)
+		} else {
+			byParent[*r.parentID] = append(byParent[*r.parentID], r)
+		}
+	}
+	for i := 0; i < len(ordered); i++ {
+		ordered = append(ordered, byParent[ordered[i].id]...)
+	}
+	return ordered
+}
+
+// isDescendant reports whether id is ancestor or one of its subcategories.
+func isDescendant(categories []Category, id, ancestor int) bool {
+	parents := make(map[int]*int, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+	for seen := 0; seen <= len(categories); seen++ {
+		if id == ancestor {
+			return true
+		}
+		parent, ok := parents[id]
+		if !ok || parent == nil {
+			return false
+		}
+		id = *parent
+	}
+	return false
+}
+
+// CategoryPaths returns the full "Parent / Child" name of every category,
+// keyed by ID.
+func CategoryPaths(categories []Category) map[int]string {
+	byID := make(map[int]Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	paths := make(map[int]string, len(categories))
+	for _, c := range categories {
+		names := []string{c.Name}
+		for parent := c.ParentID; parent != nil && len(names) <= len(categories); {
+			p, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			names = append([]string{p.Name}, names...)
+			parent = p.ParentID
+		}
+		paths[c.ID] = strings.Join(names, " / ")
+	}
+	return paths
+}
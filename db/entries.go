@@ -0,0 +1,436 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"spms/crypto"
+	"strings"
+	"time"
+)
+
+const websiteIndexPurpose = "spms website index"
+
+// entryFormat is the storage format written for new rows. Format 0 rows
+// were encrypted without associated data, and may keep website and username
+// in the plaintext columns.
+const entryFormat = 1
+
+type PasswordEntry struct {
+	ID                int
+	Website           string
+	Username          string
+	Notes             string
+	CategoryID        *int
+	EncryptedPassword []byte
+	EncryptedTOTP     []byte     // nil when the entry has no authenticator
+	DeletedAt         *time.Time // set while the entry is in the trash
+	Favorite          bool
+	LastUsedAt        *time.Time // last time the password was copied or revealed
+	UseCount          int
+}
+
+// entryRow is a passwords row as stored.
+type entryRow struct {
+	id                int
+	format            int
+	website           string
+	username          string
+	encryptedWebsite  []byte
+	encryptedUsername []byte
+	encryptedPassword []byte
+	notes             []byte
+	categoryID        *int
+	encryptedTOTP     []byte
+	deletedAt         *time.Time
+	favorite          bool
+	lastUsedAt        *time.Time
+	useCount          int
+}
+
+func (r *entryRow) isLegacy() bool {
+	return r.format < entryFormat
+}
+
+func (r *entryRow) open(keys entryKeys, field string, ciphertext []byte) ([]byte, error) {
+	if r.isLegacy() {
+		return crypto.Decrypt(ciphertext, keys.key)
+	}
+	return keys.open(r.id, field, ciphertext)
+}
+
+func (r *entryRow) decrypt(keys entryKeys) (PasswordEntry, error) {
+	entry := PasswordEntry{
+		ID:                r.id,
+		Website:           r.website,
+		Username:          r.username,
+		CategoryID:        r.categoryID,
+		EncryptedPassword: r.encryptedPassword,
+		EncryptedTOTP:     r.encryptedTOTP,
+		DeletedAt:         r.deletedAt,
+		Favorite:          r.favorite,
+		LastUsedAt:        r.lastUsedAt,
+		UseCount:          r.useCount,
+	}
+
+	if len(r.encryptedWebsite) > 0 {
+		website, err := r.open(keys, "website", r.encryptedWebsite)
+		if err != nil {
+			return entry, err
+		}
+		username, err := r.open(keys, "username", r.encryptedUsername)
+		if err != nil {
+			return entry, err
+		}
+		entry.Website = string(website)
+		entry.Username = string(username)
+	}
+
+	if len(r.notes) > 0 {
+		notes, err := r.open(keys, "notes", r.notes)
+		if err != nil {
+			return entry, err
+		}
+		entry.Notes = string(notes)
+	}
+	return entry, nil
+}
+
+// entryKeys binds entry ciphertexts to their vault, row and field through
+// associated data, so blobs cannot be swapped between rows.
+type entryKeys struct {
+	key     []byte
+	vaultID []byte
+	cipher  crypto.Algorithm
+}
+
+func loadEntryKeys(q querier, key []byte) (entryKeys, error) {
+	mk, err := getMasterKey(q)
+	if err != nil {
+		return entryKeys{}, err
+	}
+	if mk == nil || len(mk.VaultID) == 0 {
+		return entryKeys{}, errors.New("vault has no id")
+	}
+	return entryKeys{key: key, vaultID: mk.VaultID, cipher: mk.Cipher}, nil
+}
+
+func (k entryKeys) ad(id int, field string) []byte {
+	return []byte(fmt.Sprintf("%x/%d/%s", k.vaultID, id, field))
+}
+
+func (k entryKeys) seal(id int, field string, plaintext []byte) ([]byte, error) {
+	return crypto.EncryptWithAlgorithm(k.cipher, plaintext, k.key, k.ad(id, field))
+}
+
+func (k entryKeys) open(id int, field string, ciphertext []byte) ([]byte, error) {
+	return crypto.DecryptWithAD(ciphertext, k.key, k.ad(id, field))
+}
+
+// AddEntry stores a new entry and returns its ID.
+func (db *DB) AddEntry(key []byte, website, username string, password []byte, notes string, categoryID *int) (int, error) {
+	var id int
+	err := db.WithTx(func(tx *Tx) error {
+		var err error
+		id, err = tx.AddEntry(key, website, username, password, notes, categoryID)
+		return err
+	})
+	return id, err
+}
+
+func addEntry(q querier, key []byte, website, username string, password []byte, notes string, categoryID *int) (int, error) {
+	if website == "" || username == "" || len(password) == 0 {
+		return 0, errors.New("invalid entry parameters")
+	}
+
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return 0, err
+	}
+	entry := PasswordEntry{Website: website, Username: username, Notes: notes, CategoryID: categoryID}
+	if err := writeEntry(q, keys, &entry, password, true); err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
+}
+
+// UpdateEntry overwrites an entry. A changed password is kept in the
+// entry's history.
+func (db *DB) UpdateEntry(key []byte, id int, website, username string, password []byte, notes string, categoryID *int) error {
+	return db.WithTx(func(tx *Tx) error {
+		return tx.UpdateEntry(key, id, website, username, password, notes, categoryID)
+	})
+}
+
+func updateEntry(q querier, key []byte, id int, website, username string, password []byte, notes string, categoryID *int) error {
+	if website == "" || username == "" || len(password) == 0 {
+		return errors.New("invalid entry parameters")
+	}
+
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return err
+	}
+
+	rows, err := queryEntryRows(q, "WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return errors.New("entry not found")
+	}
+	if err := recordHistory(q, keys, rows[0], password); err != nil {
+		return err
+	}
+	entry := PasswordEntry{ID: id, Website: website, Username: username, Notes: notes, CategoryID: categoryID}
+	return writeEntry(q, keys, &entry, password, true)
+}
+
+// writeEntry encrypts entry and password and inserts the entry, or updates
+// it when entry.ID is set. Inserts must run inside a transaction because the
+// row ID is needed before the fields can be encrypted. modified is false when
+// the entry is only re-encrypted, which keeps its modification time.
+func writeEntry(q querier, keys entryKeys, entry *PasswordEntry, password []byte, modified bool) error {
+	if entry.ID == 0 {
+		res, err := q.Exec(
+			`INSERT INTO passwords (website, username, encrypted_password) VALUES ('', '', X'')`,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		entry.ID = int(id)
+	}
+
+	indexKey, err := crypto.DeriveSubkey(keys.key, websiteIndexPurpose)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(indexKey)
+
+	encryptedWebsite, err := keys.seal(entry.ID, "website", []byte(entry.Website))
+	if err != nil {
+		return err
+	}
+	encryptedUsername, err := keys.seal(entry.ID, "username", []byte(entry.Username))
+	if err != nil {
+		return err
+	}
+	encryptedPassword, err := keys.seal(entry.ID, "password", password)
+	if err != nil {
+		return err
+	}
+	var notes []byte
+	if entry.Notes != "" {
+		notes, err = keys.seal(entry.ID, "notes", []byte(entry.Notes))
+		if err != nil {
+			return err
+		}
+	}
+	websiteIndex := crypto.BlindIndex(indexKey, entry.Website)
+
+	_, err = q.Exec(
+		`UPDATE passwords SET 
+			website = '', 
+			username = '', 
+			encrypted_website = ?, 
+			encrypted_username = ?, 
+			website_index = ?, 
+			encrypted_password = ?, 
+			notes = ?, 
+			category_id = ?,
+			enc_format = ?,
+			updated_at = CASE WHEN ? THEN CURRENT_TIMESTAMP ELSE updated_at END
+		WHERE id = ?`,
+		encryptedWebsite, encryptedUsername, websiteIndex, encryptedPassword, notes, entry.CategoryID,
+		entryFormat, modified, entry.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.EncryptedPassword = encryptedPassword
+	return nil
+}
+
+// DeleteEntry moves an entry to the trash. Use PurgeEntry to remove it
+// permanently.
+func (db *DB) DeleteEntry(id int) error {
+	_, err := db.conn.Exec("UPDATE passwords SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", id)
+	return err
+}
+
+// DecryptPassword returns the plaintext password of entry. Callers should
+// clear the result with crypto.ClearBytes when done.
+func (db *DB) DecryptPassword(key []byte, entry PasswordEntry) ([]byte, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return keys.open(entry.ID, "password", entry.EncryptedPassword)
+}
+
+// GetAllEntries returns all entries outside the trash with their metadata
+// decrypted, ordered by website.
+func (db *DB) GetAllEntries(key []byte) ([]PasswordEntry, error) {
+	return getAllEntries(db.conn, key)
+}
+
+// GetEntry returns the entry with the given ID, unless it is in the trash.
+func (db *DB) GetEntry(key []byte, id int) (PasswordEntry, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return PasswordEntry{}, err
+	}
+
+	rows, err := queryEntryRows(db.conn, "WHERE id = ? AND deleted_at IS NULL", id)
+	if err != nil {
+		return PasswordEntry{}, err
+	}
+	if len(rows) == 0 {
+		return PasswordEntry{}, fmt.Errorf("entry %d not found", id)
+	}
+	return rows[0].decrypt(keys)
+}
+
+// FindByWebsite returns the entries whose website matches exactly, ignoring
+// case, using the blind index.
+func (db *DB) FindByWebsite(key []byte, website string) ([]PasswordEntry, error) {
+	indexKey, err := crypto.DeriveSubkey(key, websiteIndexPurpose)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(indexKey)
+
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryEntryRows(db.conn, "WHERE website_index = ? AND deleted_at IS NULL", crypto.BlindIndex(indexKey, website))
+	if err != nil {
+		return nil, err
+	}
+	return decryptRows(rows, keys)
+}
+
+func getAllEntries(q querier, key []byte) ([]PasswordEntry, error) {
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryEntryRows(q, "WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	return decryptRows(rows, keys)
+}
+
+func decryptRows(rows []entryRow, keys entryKeys) ([]PasswordEntry, error) {
+	entries := make([]PasswordEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := row.decrypt(keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt entry %d: %w", row.id, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Website) < strings.ToLower(entries[j].Website)
+	})
+	return entries, nil
+}
+
+func queryEntryRows(q querier, where string, args ...any) ([]entryRow, error) {
+	rows, err := q.Query(
+		`SELECT id, enc_format, website, username, encrypted_website, encrypted_username, encrypted_password, notes, category_id, encrypted_totp, deleted_at, favorite, last_used_at, use_count 
+		FROM passwords `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []entryRow
+	for rows.Next() {
+		var row entryRow
+		if err := rows.Scan(
+			&row.id,
+			&row.format,
+			&row.website,
+			&row.username,
+			&row.encryptedWebsite,
+			&row.encryptedUsername,
+			&row.encryptedPassword,
+			&row.notes,
+			&row.categoryID,
+			&row.encryptedTOTP,
+			&row.deletedAt,
+			&row.favorite,
+			&row.lastUsedAt,
+			&row.useCount,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return result, nil
+}
+
+// rewriteEntries decrypts entries with oldKeys and stores them in the
+// current format under newKeys. When legacyOnly is set, rows that are
+// already in the current format are skipped. It returns a *RekeyError
+// listing rows that could not be converted.
+func rewriteEntries(q querier, oldKeys, newKeys entryKeys, legacyOnly bool) error {
+	rows, err := queryEntryRows(q, "")
+	if err != nil {
+		return err
+	}
+
+	var failed []int
+	for _, row := range rows {
+		if legacyOnly && !row.isLegacy() {
+			continue
+		}
+		if err := rewriteEntry(q, row, oldKeys, newKeys); err != nil {
+			failed = append(failed, row.id)
+		}
+	}
+	if len(failed) > 0 {
+		return &RekeyError{Failed: failed}
+	}
+	return nil
+}
+
+func rewriteEntry(q querier, row entryRow, oldKeys, newKeys entryKeys) error {
+	entry, err := row.decrypt(oldKeys)
+	if err != nil {
+		return err
+	}
+
+	password, err := row.open(oldKeys, "password", row.encryptedPassword)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(password)
+
+	if err := writeEntry(q, newKeys, &entry, password, false); err != nil {
+		return err
+	}
+	if err := rewriteTOTP(q, row, oldKeys, newKeys); err != nil {
+		return err
+	}
+	if err := rewriteHistory(q, entry.ID, oldKeys, newKeys); err != nil {
+		return err
+	}
+	return rewriteFields(q, entry.ID, oldKeys, newKeys)
+}
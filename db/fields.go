@@ -0,0 +1,224 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"
+	FieldHidden FieldType = "hidden"
+	FieldURL    FieldType = "url"
+	FieldEmail  FieldType = "email"
+	FieldPIN    FieldType = "pin"
+)
+
+// FieldTypes lists the supported custom field types in display order.
+var FieldTypes = []FieldType{FieldText, FieldHidden, FieldURL, FieldEmail, FieldPIN}
+
+// IsSecret reports whether values of this type should be masked.
+func (t FieldType) IsSecret() bool {
+	return t == FieldHidden || t == FieldPIN
+}
+
+// Validate checks that value is acceptable for the field type.
+func (t FieldType) Validate(value string) error {
+	switch t {
+	case FieldText, FieldHidden:
+		return nil
+	case FieldURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid URL %q", value)
+		}
+		return nil
+	case FieldEmail:
+		if _, err := mail.ParseAddress(value); err != nil {
+			return fmt.Errorf("invalid email address %q", value)
+		}
+		return nil
+	case FieldPIN:
+		if value == "" || strings.Trim(value, "0123456789") != "" {
+			return errors.New("PIN must contain only digits")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown field type %q", t)
+}
+
+type CustomField struct {
+	ID      int
+	EntryID int
+	Type    FieldType
+	Name    string
+	Value   string
+}
+
+func (db *DB) GetFields(key []byte, entryID int) ([]CustomField, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return getFields(db.conn, keys, entryID)
+}
+
+// AddField adds a custom field to an entry and returns its ID.
+func (db *DB) AddField(key []byte, entryID int, fieldType FieldType, name, value string) (int, error) {
+	field := CustomField{EntryID: entryID, Type: fieldType, Name: name, Value: value}
+	err := db.WithTx(func(tx *Tx) error {
+		keys, err := loadEntryKeys(tx.tx, key)
+		if err != nil {
+			return err
+		}
+		return writeField(tx.tx, keys, &field)
+	})
+	return field.ID, err
+}
+
+func (db *DB) UpdateField(key []byte, id, entryID int, fieldType FieldType, name, value string) error {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return err
+	}
+	field := CustomField{ID: id, EntryID: entryID, Type: fieldType, Name: name, Value: value}
+	return writeField(db.conn, keys, &field)
+}
+
+func (db *DB) DeleteField(id int) error {
+	_, err := db.conn.Exec("DELETE FROM entry_fields WHERE id = ?", id)
+	return err
+}
+
+// SetFields replaces all custom fields of an entry.
+func (db *DB) SetFields(key []byte, entryID int, fields []CustomField) error {
+	return db.WithTx(func(tx *Tx) error {
+		return tx.SetFields(key, entryID, fields)
+	})
+}
+
+func setFields(q querier, key []byte, entryID int, fields []CustomField) error {
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return err
+	}
+	if _, err := q.Exec("DELETE FROM entry_fields WHERE entry_id = ?", entryID); err != nil {
+		return err
+	}
+	for i := range fields {
+		field := fields[i]
+		field.ID = 0
+		field.EntryID = entryID
+		if err := writeField(q, keys, &field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldAD(id int, part string) string {
+	return fmt.Sprintf("field/%d/%s", id, part)
+}
+
+// writeField encrypts and stores field, inserting it when field.ID is
+// zero. Inserts must run inside a transaction.
+func writeField(q querier, keys entryKeys, field *CustomField) error {
+	if field.Name == "" {
+		return errors.New("field name cannot be empty")
+	}
+	if err := field.Type.Validate(field.Value); err != nil {
+		return err
+	}
+
+	if field.ID == 0 {
+		res, err := q.Exec(
+			`INSERT INTO entry_fields (entry_id, type, encrypted_name, encrypted_value, position) 
+			VALUES (?, ?, X'', X'', (SELECT COUNT(*) FROM entry_fields WHERE entry_id = ?))`,
+			field.EntryID, field.Type, field.EntryID,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		field.ID = int(id)
+	}
+
+	name, err := keys.seal(field.EntryID, fieldAD(field.ID, "name"), []byte(field.Name))
+	if err != nil {
+		return err
+	}
+	value, err := keys.seal(field.EntryID, fieldAD(field.ID, "value"), []byte(field.Value))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(
+		`UPDATE entry_fields SET type = ?, encrypted_name = ?, encrypted_value = ? 
+		WHERE id = ? AND entry_id = ?`,
+		field.Type, name, value, field.ID, field.EntryID,
+	)
+	return err
+}
+
+func getFields(q querier, keys entryKeys, entryID int) ([]CustomField, error) {
+	rows, err := q.Query(
+		`SELECT id, type, encrypted_name, encrypted_value FROM entry_fields 
+		WHERE entry_id = ? ORDER BY position, id`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fields: %w", err)
+	}
+	defer rows.Close()
+
+	type fieldRow struct {
+		field       CustomField
+		name, value []byte
+	}
+	var raw []fieldRow
+	for rows.Next() {
+		r := fieldRow{field: CustomField{EntryID: entryID}}
+		if err := rows.Scan(&r.field.ID, &r.field.Type, &r.name, &r.value); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		raw = append(raw, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	fields := make([]CustomField, 0, len(raw))
+	for _, r := range raw {
+		name, err := keys.open(entryID, fieldAD(r.field.ID, "name"), r.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field %d: %w", r.field.ID, err)
+		}
+		value, err := keys.open(entryID, fieldAD(r.field.ID, "value"), r.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field %d: %w", r.field.ID, err)
+		}
+		r.field.Name = string(name)
+		r.field.Value = string(value)
+		fields = append(fields, r.field)
+	}
+	return fields, nil
+}
+
+// rewriteFields re-encrypts the custom fields of an entry under newKeys.
+func rewriteFields(q querier, entryID int, oldKeys, newKeys entryKeys) error {
+	fields, err := getFields(q, oldKeys, entryID)
+	if err != nil {
+		return err
+	}
+	for i := range fields {
+		if err := writeField(q, newKeys, &fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
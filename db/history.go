@@ -0,0 +1,165 @@
+package db
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"spms/crypto"
+	"time"
+)
+
+// HistoryEntry is a previous password of an entry.
+type HistoryEntry struct {
+	ID                int
+	EntryID           int
+	EncryptedPassword []byte
+	CreatedAt         time.Time // when the password was replaced
+}
+
+func historyAD(id int) string {
+	return fmt.Sprintf("history/%d", id)
+}
+
+// GetHistory returns the previous passwords of an entry, newest first.
+func (db *DB) GetHistory(entryID int) ([]HistoryEntry, error) {
+	return getHistory(db.conn, entryID)
+}
+
+// DecryptHistory returns the plaintext of a previous password. Callers
+// should clear the result with crypto.ClearBytes when done.
+func (db *DB) DecryptHistory(key []byte, h HistoryEntry) ([]byte, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return keys.open(h.EntryID, historyAD(h.ID), h.EncryptedPassword)
+}
+
+// RestorePassword makes a previous password current again. The password it
+// replaces is kept in the history.
+func (db *DB) RestorePassword(key []byte, historyID int) error {
+	return db.WithTx(func(tx *Tx) error {
+		keys, err := loadEntryKeys(tx.tx, key)
+		if err != nil {
+			return err
+		}
+
+		var h HistoryEntry
+		err = tx.tx.QueryRow(
+			"SELECT id, entry_id, encrypted_password FROM password_history WHERE id = ?", historyID,
+		).Scan(&h.ID, &h.EntryID, &h.EncryptedPassword)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("history entry not found")
+			}
+			return err
+		}
+
+		password, err := keys.open(h.EntryID, historyAD(h.ID), h.EncryptedPassword)
+		if err != nil {
+			return err
+		}
+		defer crypto.ClearBytes(password)
+
+		rows, err := queryEntryRows(tx.tx, "WHERE id = ?", h.EntryID)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return errors.New("entry not found")
+		}
+		entry, err := rows[0].decrypt(keys)
+		if err != nil {
+			return err
+		}
+
+		if err := recordHistory(tx.tx, keys, rows[0], password); err != nil {
+			return err
+		}
+		if err := writeEntry(tx.tx, keys, &entry, password, true); err != nil {
+			return err
+		}
+		_, err = tx.tx.Exec("DELETE FROM password_history WHERE id = ?", h.ID)
+		return err
+	})
+}
+
+// recordHistory stores the current password of row before it is replaced
+// by password. Nothing is recorded when the password does not change.
+// It must run inside a transaction.
+func recordHistory(q querier, keys entryKeys, row entryRow, password []byte) error {
+	current, err := row.open(keys, "password", row.encryptedPassword)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(current)
+
+	if subtle.ConstantTimeCompare(current, password) == 1 {
+		return nil
+	}
+
+	res, err := q.Exec(
+		"INSERT INTO password_history (entry_id, encrypted_password) VALUES (?, X'')", row.id,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return writeHistory(q, keys, row.id, int(id), current)
+}
+
+func writeHistory(q querier, keys entryKeys, entryID, id int, password []byte) error {
+	encrypted, err := keys.seal(entryID, historyAD(id), password)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("UPDATE password_history SET encrypted_password = ? WHERE id = ?", encrypted, id)
+	return err
+}
+
+func getHistory(q querier, entryID int) ([]HistoryEntry, error) {
+	rows, err := q.Query(
+		`SELECT id, encrypted_password, created_at FROM password_history 
+		WHERE entry_id = ? ORDER BY created_at DESC, id DESC`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []HistoryEntry
+	for rows.Next() {
+		h := HistoryEntry{EntryID: entryID}
+		if err := rows.Scan(&h.ID, &h.EncryptedPassword, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history: %w", err)
+		}
+		history = append(history, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return history, nil
+}
+
+// rewriteHistory re-encrypts the password history of an entry under newKeys.
+func rewriteHistory(q querier, entryID int, oldKeys, newKeys entryKeys) error {
+	history, err := getHistory(q, entryID)
+	if err != nil {
+		return err
+	}
+	for _, h := range history {
+		password, err := oldKeys.open(entryID, historyAD(h.ID), h.EncryptedPassword)
+		if err != nil {
+			return err
+		}
+		err = writeHistory(q, newKeys, entryID, h.ID, password)
+		crypto.ClearBytes(password)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// freeAttempts is how many consecutive failed logins are allowed
+	// before further attempts are delayed.
+	freeAttempts = 3
+	// maxLoginDelay caps the exponential back-off between attempts.
+	maxLoginDelay = 5 * time.Minute
+	// LockoutDuration is how long logins are refused once the configured
+	// number of failures has been reached and the action is FailureLock.
+	LockoutDuration = time.Hour
+)
+
+// ErrVaultWiped is returned by the failed login that erased the vault.
+var ErrVaultWiped = errors.New("too many failed login attempts; the vault has been erased")
+
+// FailureAction is what happens once the configured number of consecutive
+// failed logins has been reached.
+type FailureAction string
+
+const (
+	FailureLock FailureAction = "lock"
+	FailureWipe FailureAction = "wipe"
+)
+
+// LoginDelayError is returned when a login is attempted before the
+// back-off from previous failures has passed.
+type LoginDelayError struct {
+	Until time.Time
+}
+
+func (e *LoginDelayError) Error() string {
+	wait := time.Until(e.Until).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("too many failed login attempts; try again in %s", wait)
+}
+
+// LoginInfo describes the failed attempts between the two most recent
+// successful logins.
+type LoginInfo struct {
+	FailedAttempts int
+	LastFailure    *time.Time
+	PreviousLogin  *time.Time
+}
+
+// loginDelay returns how long to wait after the given number of
+// consecutive failures.
+func loginDelay(failures int) time.Duration {
+	if failures < freeAttempts {
+		return 0
+	}
+	shift := failures - freeAttempts
+	if shift >= 16 {
+		return maxLoginDelay
+	}
+	return min(time.Second<<shift, maxLoginDelay)
+}
+
+// LoginDelay returns when the next login may be attempted. It returns the
+// zero time if a login may be attempted now.
+func (db *DB) LoginDelay() (time.Time, error) {
+	failures, last, err := db.recentFailures()
+	if err != nil || failures == 0 {
+		return time.Time{}, err
+	}
+
+	limit, action, err := db.FailedLoginLimit()
+	if err != nil {
+		return time.Time{}, err
+	}
+	until := last.Add(loginDelay(failures))
+	if limit > 0 && failures >= limit && action == FailureLock {
+		until = last.Add(LockoutDuration)
+	}
+	if !time.Now().Before(until) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+// lastSuccess selects the ID of the latest successful login, or 0.
+const lastSuccess = "(SELECT COALESCE(MAX(id), 0) FROM login_attempts WHERE success = 1)"
+
+// attemptTimes returns the times of the login attempts matching where,
+// newest first.
+func (db *DB) attemptTimes(where string) ([]time.Time, error) {
+	rows, err := db.conn.Query("SELECT attempted_at FROM login_attempts WHERE " + where + " ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// recentFailures returns the number of failed logins since the last
+// successful one and the time of the latest.
+func (db *DB) recentFailures() (int, time.Time, error) {
+	times, err := db.attemptTimes("success = 0 AND id > " + lastSuccess)
+	if err != nil || len(times) == 0 {
+		return 0, time.Time{}, err
+	}
+	return len(times), times[0], nil
+}
+
+// recordLogin logs a successful login, keeping only the attempts since the
+// previous one for LastLoginInfo.
+func (db *DB) recordLogin() error {
+	return db.WithTx(func(tx *Tx) error {
+		_, err := tx.tx.Exec("DELETE FROM login_attempts WHERE id < " + lastSuccess)
+		if err != nil {
+			return err
+		}
+		_, err = tx.tx.Exec("INSERT INTO login_attempts (attempted_at, success) VALUES (?, 1)", time.Now().UTC())
+		return err
+	})
+}
+
+// recordFailedLogin logs a failed login and applies the configured action
+// once the limit has been reached.
+func (db *DB) recordFailedLogin() error {
+	_, err := db.conn.Exec("INSERT INTO login_attempts (attempted_at, success) VALUES (?, 0)", time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	limit, action, err := db.FailedLoginLimit()
+	if err != nil || limit == 0 || action != FailureWipe {
+		return err
+	}
+	failures, _, err := db.recentFailures()
+	if err != nil || failures < limit {
+		return err
+	}
+	if err := db.wipe(); err != nil {
+		return fmt.Errorf("failed to erase vault: %w", err)
+	}
+	return ErrVaultWiped
+}
+
+// LastLoginInfo reports the failed attempts made before the latest
+// successful login.
+func (db *DB) LastLoginInfo() (LoginInfo, error) {
+	var info LoginInfo
+	failures, err := db.attemptTimes("success = 0 AND id < " + lastSuccess)
+	if err != nil {
+		return info, err
+	}
+	logins, err := db.attemptTimes("success = 1")
+	if err != nil {
+		return info, err
+	}
+
+	info.FailedAttempts = len(failures)
+	if len(failures) > 0 {
+		info.LastFailure = &failures[0]
+	}
+	if len(logins) > 1 {
+		info.PreviousLogin = &logins[1]
+	}
+	return info, nil
+}
+
+// wipe erases the vault key and every entry. The vault is left empty, as
+// if it had never been initialised.
+func (db *DB) wipe() error {
+	err := db.WithTx(func(tx *Tx) error {
+		for _, table := range []string{"passwords", "tags", "categories", "master_key", "login_attempts"} {
+			if _, err := tx.tx.Exec("DELETE FROM " + table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// secure_delete overwrites the freed pages, but their old contents
+	// may still be in the write-ahead log until it is checkpointed.
+	_, err = db.conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migration upgrades the schema by one version. Migrations are applied in
+// order and never edited once released; add a new one instead.
+type migration struct {
+	name string
+	up   func(q querier) error
+}
+
+// The first migrations tolerate columns that already exist, because vaults
+// created before the runner was introduced had their columns added ad hoc
+// and all report user_version 0.
+var migrations = []migration{
+	{"create base tables", execAll(
+		`CREATE TABLE IF NOT EXISTS master_key (
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            salt BLOB NOT NULL,
+            encrypted_check BLOB NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );`,
+		`CREATE TABLE IF NOT EXISTS passwords (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            website TEXT NOT NULL,
+            username TEXT NOT NULL,
+            encrypted_password BLOB NOT NULL,
+            notes BLOB,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            category_id INTEGER,
+            FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS categories (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT NOT NULL UNIQUE
+        );`,
+	)},
+	// Vaults created before KDF parameters were stored keep the legacy
+	// values through the column defaults.
+	{"store kdf parameters", addColumns("master_key",
+		"kdf_memory INTEGER NOT NULL DEFAULT 65536",
+		"kdf_iterations INTEGER NOT NULL DEFAULT 3",
+		"kdf_parallelism INTEGER NOT NULL DEFAULT 4",
+		"kdf_key_length INTEGER NOT NULL DEFAULT 32",
+	)},
+	{"wrap vault key", addColumns("master_key",
+		"wrapped_key BLOB",
+	)},
+	{"encrypt entry metadata", func(q querier) error {
+		err := addColumns("passwords",
+			"encrypted_website BLOB",
+			"encrypted_username BLOB",
+			"website_index BLOB",
+		)(q)
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec("CREATE INDEX IF NOT EXISTS idx_passwords_website_index ON passwords(website_index)")
+		return err
+	}},
+	{"bind ciphertexts to vault", func(q querier) error {
+		if err := addColumns("master_key", "vault_id BLOB")(q); err != nil {
+			return err
+		}
+		return addColumns("passwords", "enc_format INTEGER NOT NULL DEFAULT 0")(q)
+	}},
+	{"selectable cipher", addColumns("master_key",
+		"cipher INTEGER NOT NULL DEFAULT 1",
+	)},
+	{"custom fields", execAll(
+		`CREATE TABLE entry_fields (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            entry_id INTEGER NOT NULL,
+            type TEXT NOT NULL,
+            encrypted_name BLOB NOT NULL,
+            encrypted_value BLOB NOT NULL,
+            position INTEGER NOT NULL DEFAULT 0,
+            FOREIGN KEY (entry_id) REFERENCES passwords(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX idx_entry_fields_entry_id ON entry_fields(entry_id)`,
+	)},
+	{"totp secrets", execAll(
+		`ALTER TABLE passwords ADD COLUMN encrypted_totp BLOB`,
+	)},
+	{"password history", execAll(
+		`CREATE TABLE password_history (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            entry_id INTEGER NOT NULL,
+            encrypted_password BLOB NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (entry_id) REFERENCES passwords(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX idx_password_history_entry_id ON password_history(entry_id)`,
+	)},
+	{"soft delete", execAll(
+		`ALTER TABLE passwords ADD COLUMN deleted_at TIMESTAMP`,
+		`CREATE INDEX idx_passwords_deleted_at ON passwords(deleted_at)`,
+	)},
+	{"settings", execAll(
+		`CREATE TABLE settings (
+            name TEXT PRIMARY KEY,
+            value TEXT NOT NULL
+        );`,
+	)},
+	{"nested categories", execAll(
+		`ALTER TABLE categories ADD COLUMN parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL`,
+		`ALTER TABLE categories ADD COLUMN color TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE categories ADD COLUMN icon TEXT NOT NULL DEFAULT ''`,
+	)},
+	{"tags", execAll(
+		`CREATE TABLE tags (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT NOT NULL UNIQUE COLLATE NOCASE
+        );`,
+		`CREATE TABLE entry_tags (
+            entry_id INTEGER NOT NULL,
+            tag_id INTEGER NOT NULL,
+            PRIMARY KEY (entry_id, tag_id),
+            FOREIGN KEY (entry_id) REFERENCES passwords(id) ON DELETE CASCADE,
+            FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+        );`,
+		`CREATE INDEX idx_entry_tags_tag_id ON entry_tags(tag_id)`,
+	)},
+	{"usage tracking", execAll(
+		`ALTER TABLE passwords ADD COLUMN favorite INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE passwords ADD COLUMN last_used_at TIMESTAMP`,
+		`ALTER TABLE passwords ADD COLUMN use_count INTEGER NOT NULL DEFAULT 0`,
+	)},
+	{"login attempts", execAll(
+		`CREATE TABLE login_attempts (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            attempted_at TIMESTAMP NOT NULL,
+            success INTEGER NOT NULL
+        );`,
+	)},
+	// Category names only need to be unique among siblings. SQLite cannot
+	// drop a constraint, so the table is rebuilt.
+	{"unique category names per parent", execAll(
+		`CREATE TABLE categories_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT NOT NULL,
+            parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+            color TEXT NOT NULL DEFAULT '',
+            icon TEXT NOT NULL DEFAULT '',
+            UNIQUE (parent_id, name)
+        );`,
+		`INSERT INTO categories_new (id, name, parent_id, color, icon)
+            SELECT id, name, parent_id, color, icon FROM categories`,
+		`DROP TABLE categories`,
+		`ALTER TABLE categories_new RENAME TO categories`,
+		// UNIQUE treats NULLs as distinct, so top-level names need an index
+		// of their own.
+		`CREATE UNIQUE INDEX idx_categories_top_level_name ON categories(name) WHERE parent_id IS NULL`,
+	)},
+	// Tag names are encrypted like entry metadata and matched through a
+	// blind index. Existing names stay in the name column until the vault
+	// is next unlocked, when they are encrypted.
+	{"encrypt tag names", execAll(
+		`CREATE TABLE tags_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT,
+            encrypted_name BLOB,
+            name_index BLOB UNIQUE
+        );`,
+		`INSERT INTO tags_new (id, name) SELECT id, name FROM tags`,
+		`DROP TABLE tags`,
+		`ALTER TABLE tags_new RENAME TO tags`,
+	)},
+	// Category names and styles are encrypted like tags. Names stay unique
+	// among siblings through their blind index; existing rows keep their
+	// plaintext columns until the vault is next unlocked.
+	{"encrypt categories", execAll(
+		`CREATE TABLE categories_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT,
+            parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+            color TEXT NOT NULL DEFAULT '',
+            icon TEXT NOT NULL DEFAULT '',
+            encrypted_name BLOB,
+            encrypted_color BLOB,
+            encrypted_icon BLOB,
+            name_index BLOB,
+            UNIQUE (parent_id, name_index)
+        );`,
+		`INSERT INTO categories_new (id, name, parent_id, color, icon)
+            SELECT id, name, parent_id, color, icon FROM categories`,
+		`DROP TABLE categories`,
+		`ALTER TABLE categories_new RENAME TO categories`,
+		`CREATE UNIQUE INDEX idx_categories_top_level_name_index ON categories(name_index) WHERE parent_id IS NULL`,
+	)},
+}
+
+// migrate brings the schema up to date in a single transaction, using
+// PRAGMA user_version to record the applied version. Foreign keys are not
+// enforced while migrating, so that rebuilding a table does not cascade to
+// the rows referring to it; they are checked before committing instead.
+func migrate(conn *sql.DB) error {
+	var version int
+	if err := conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("vault schema version %d is newer than supported version %d", version, len(migrations))
+	}
+	if version == len(migrations) {
+		return nil
+	}
+
+	// The pragma applies to a single connection, which must also run the
+	// transaction.
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer c.Close()
+	if _, err := c.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer c.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := version; i < len(migrations); i++ {
+		if err := migrations[i].up(tx); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", i+1, migrations[i].name, err)
+		}
+	}
+
+	if err := checkForeignKeys(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// checkForeignKeys fails if any row refers to a missing row.
+func checkForeignKeys(q querier) error {
+	rows, err := q.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table string
+		var rowID sql.NullInt64
+		var parent string
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("migration left row %d of %s referring to a missing %s", rowID.Int64, table, parent)
+	}
+	return rows.Err()
+}
+
+func execAll(queries ...string) func(q querier) error {
+	return func(q querier) error {
+		for _, query := range queries {
+			if _, err := q.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns adds each "name definition" column to table unless a column
+// with that name already exists.
+func addColumns(table string, columns ...string) func(q querier) error {
+	return func(q querier) error {
+		existing, err := tableColumns(q, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			name := strings.Fields(column)[0]
+			if existing[name] {
+				continue
+			}
+			if _, err := q.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func tableColumns(q querier, table string) (map[string]bool, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"spms/crypto"
+	"strings"
+	"testing"
+)
+
+// baselineSchema is the schema of vaults created before migrations were
+// introduced. Those vaults report user_version 0.
+var baselineSchema = []string{
+	`CREATE TABLE IF NOT EXISTS master_key (
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            salt BLOB NOT NULL,
+            encrypted_check BLOB NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );`,
+	`CREATE TABLE IF NOT EXISTS passwords (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            website TEXT NOT NULL,
+            username TEXT NOT NULL,
+            encrypted_password BLOB NOT NULL,
+            notes BLOB,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            category_id INTEGER,
+            FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+        );`,
+	`CREATE TABLE IF NOT EXISTS categories (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            name TEXT NOT NULL UNIQUE
+        );`,
+}
+
+const testPassword = "correct horse battery staple"
+
+// legacyEncrypt encrypts like the baseline: a random nonce followed by the
+// AES-GCM ciphertext, without an envelope or associated data.
+func legacyEncrypt(t *testing.T, plaintext, key []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil)
+}
+
+// createBaselineVault writes a vault as the baseline would, holding one
+// entry, and returns its path.
+func createBaselineVault(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vault.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, query := range baselineSchema {
+		if _, err := conn.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	salt, err := crypto.GenerateSecureKey(int(crypto.LegacyParams.SaltLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.DeriveKeyWithParams(testPassword, salt, crypto.LegacyParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := legacyEncrypt(t, []byte("SPMS"), key)
+	if _, err := conn.Exec("INSERT INTO master_key (id, salt, encrypted_check) VALUES (1, ?, ?)", salt, check); err != nil {
+		t.Fatal(err)
+	}
+
+	password := legacyEncrypt(t, []byte("hunter2"), key)
+	if _, err := conn.Exec(
+		"INSERT INTO passwords (website, username, encrypted_password) VALUES (?, ?, ?)",
+		"example.com", "alice", password,
+	); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func userVersion(t *testing.T, db *DB) int {
+	t.Helper()
+	var version int
+	if err := db.conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func schema(t *testing.T, db *DB) string {
+	t.Helper()
+	rows, err := db.conn.Query("SELECT sql FROM sqlite_master WHERE sql IS NOT NULL ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		statements = append(statements, s)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(statements, "\n")
+}
+
+func TestMigrateBaselineVault(t *testing.T) {
+	path := createBaselineVault(t)
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	if got := userVersion(t, db); got != len(migrations) {
+		t.Errorf("user_version = %d, want %d", got, len(migrations))
+	}
+
+	key, err := db.UnlockVault(testPassword)
+	if err != nil {
+		t.Fatalf("UnlockVault: %v", err)
+	}
+	defer crypto.ClearBytes(key)
+
+	entries, err := db.GetAllEntries(key)
+	if err != nil {
+		t.Fatalf("GetAllEntries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if entries[0].Website != "example.com" || entries[0].Username != "alice" {
+		t.Errorf("entry = %s/%s, want example.com/alice", entries[0].Website, entries[0].Username)
+	}
+	password, err := db.DecryptPassword(key, entries[0])
+	if err != nil {
+		t.Fatalf("DecryptPassword: %v", err)
+	}
+	if string(password) != "hunter2" {
+		t.Errorf("password = %q, want %q", password, "hunter2")
+	}
+
+	before := schema(t, db)
+	db.Close()
+
+	// Opening an up-to-date vault again must not change it.
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("second NewDB: %v", err)
+	}
+	defer db.Close()
+	if got := userVersion(t, db); got != len(migrations) {
+		t.Errorf("user_version after reopening = %d, want %d", got, len(migrations))
+	}
+	if after := schema(t, db); after != before {
+		t.Errorf("schema changed on reopening:\nbefore:\n%s\nafter:\n%s", before, after)
+	}
+}
+
+func TestMigrateRollsBackOnFailure(t *testing.T) {
+	path := createBaselineVault(t)
+
+	saved := migrations
+	defer func() { migrations = saved }()
+	migrations = append(migrations[:len(saved):len(saved)], migration{"failing", func(q querier) error {
+		if _, err := q.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+
+	if db, err := NewDB(path); err == nil {
+		db.Close()
+		t.Fatal("NewDB succeeded with a failing migration")
+	} else if !strings.Contains(err.Error(), "boom") {
+		t.Errorf("NewDB error = %v, want the migration error", err)
+	}
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	db := &DB{conn: conn}
+
+	if got := userVersion(t, db); got != 0 {
+		t.Errorf("user_version = %d after a failed migration, want 0", got)
+	}
+	columns, err := tableColumns(conn, "passwords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns["encrypted_website"] {
+		t.Error("columns from earlier migrations were not rolled back")
+	}
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("table created by the failing migration was not rolled back")
+	}
+	if err := conn.QueryRow("SELECT COUNT(*) FROM passwords").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d entries after a failed migration, want 1", n)
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"strings"
+)
+
+// EntryFilter selects entries for SearchEntries. The zero value matches
+// every entry outside the trash.
+type EntryFilter struct {
+	// Query is matched case-insensitively against the website, username,
+	// category path, tags and notes.
+	Query string
+	// Categories restricts the result to entries in one of these
+	// categories or their subcategories when not empty.
+	Categories []int
+	// Tags restricts the result to entries carrying all of these tags
+	// when not empty.
+	Tags []int
+	Sort SortOrder
+}
+
+// SearchEntries returns the entries outside the trash that match filter,
+// in the filter's sort order. Category and tag filtering happens in SQL; the text
+// query is matched after decryption because entry metadata is encrypted.
+func (db *DB) SearchEntries(key []byte, filter EntryFilter) ([]PasswordEntry, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+
+	where := "WHERE deleted_at IS NULL"
+	var args []any
+	if len(filter.Categories) > 0 {
+		// Entries in subcategories belong to their parents too.
+		where += ` AND category_id IN (WITH RECURSIVE selected(id) AS (
+				SELECT id FROM categories WHERE id IN (?` + strings.Repeat(", ?", len(filter.Categories)-1) + `)
+				UNION SELECT c.id FROM categories c JOIN selected s ON c.parent_id = s.id
+			) SELECT id FROM selected)`
+		for _, id := range filter.Categories {
+			args = append(args, id)
+		}
+	}
+	if len(filter.Tags) > 0 {
+		where += ` AND id IN (SELECT entry_id FROM entry_tags WHERE tag_id IN (?` +
+			strings.Repeat(", ?", len(filter.Tags)-1) + `) GROUP BY entry_id HAVING COUNT(*) = ?)`
+		for _, id := range filter.Tags {
+			args = append(args, id)
+		}
+		args = append(args, len(filter.Tags))
+	}
+
+	rows, err := queryEntryRows(db.conn, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decryptRows(rows, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	sortEntries(entries, filter.Sort)
+
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	if query == "" {
+		return entries, nil
+	}
+
+	categories, err := getCategories(db.conn, keys)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := CategoryPaths(categories)
+	tagNames, err := entryTagNames(db.conn, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := entries[:0]
+	for _, entry := range entries {
+		category := ""
+		if entry.CategoryID != nil {
+			category = categoryNames[*entry.CategoryID]
+		}
+		values := append([]string{entry.Website, entry.Username, category, entry.Notes}, tagNames[entry.ID]...)
+		for _, value := range values {
+			if strings.Contains(strings.ToLower(value), query) {
+				matched = append(matched, entry)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
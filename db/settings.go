@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const settingTrashRetention = "trash_retention_days"
+
+// DefaultTrashRetention is how long deleted entries are kept when no
+// retention has been configured.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// GetSetting returns the stored value of a vault setting and whether it
+// was set.
+func (db *DB) GetSetting(name string) (string, bool, error) {
+	var value string
+	err := db.conn.QueryRow("SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get setting %s: %w", name, err)
+	}
+	return value, true, nil
+}
+
+func (db *DB) SetSetting(name, value string) error {
+	_, err := db.conn.Exec("INSERT OR REPLACE INTO settings (name, value) VALUES (?, ?)", name, value)
+	return err
+}
+
+// getIntSetting returns the integer value of a setting, or def if unset.
+func (db *DB) getIntSetting(name string, def int) (int, error) {
+	value, ok, err := db.GetSetting(name)
+	if err != nil || !ok {
+		return def, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for setting %s: %q", name, value)
+	}
+	return n, nil
+}
+
+// TrashRetention returns how long deleted entries are kept before they are
+// purged. Zero means they are kept until purged by hand.
+func (db *DB) TrashRetention() (time.Duration, error) {
+	days, err := db.getIntSetting(settingTrashRetention, int(DefaultTrashRetention/(24*time.Hour)))
+	return time.Duration(days) * 24 * time.Hour, err
+}
+
+// SetTrashRetention sets the trash retention, rounded down to whole days.
+func (db *DB) SetTrashRetention(retention time.Duration) error {
+	if retention < 0 {
+		return errors.New("retention cannot be negative")
+	}
+	return db.SetSetting(settingTrashRetention, strconv.Itoa(int(retention/(24*time.Hour))))
+}
+
+const settingSortOrder = "sort_order"
+
+// SortOrder returns the sort order last chosen for the password list.
+func (db *DB) SortOrder() (SortOrder, error) {
+	order, err := db.getIntSetting(settingSortOrder, int(SortByWebsite))
+	return SortOrder(order), err
+}
+
+func (db *DB) SetSortOrder(order SortOrder) error {
+	return db.SetSetting(settingSortOrder, strconv.Itoa(int(order)))
+}
+
+const (
+	settingAutoLock       = "auto_lock_minutes"
+	settingLockOnMinimize = "lock_on_minimize"
+)
+
+// DefaultAutoLock is the inactivity timeout used when none has been
+// configured.
+const DefaultAutoLock = 5 * time.Minute
+
+// AutoLockTimeout returns how long the vault may stay idle before it is
+// locked. Zero disables the inactivity lock.
+func (db *DB) AutoLockTimeout() (time.Duration, error) {
+	minutes, err := db.getIntSetting(settingAutoLock, int(DefaultAutoLock/time.Minute))
+	return time.Duration(minutes) * time.Minute, err
+}
+
+// SetAutoLockTimeout sets the inactivity timeout, rounded down to whole
+// minutes.
+func (db *DB) SetAutoLockTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+	return db.SetSetting(settingAutoLock, strconv.Itoa(int(timeout/time.Minute)))
+}
+
+// LockOnMinimize reports whether the vault locks when the app is
+// minimized or moved to the background.
+func (db *DB) LockOnMinimize() (bool, error) {
+	enabled, err := db.getIntSetting(settingLockOnMinimize, 0)
+	return enabled != 0, err
+}
+
+func (db *DB) SetLockOnMinimize(enabled bool) error {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+	return db.SetSetting(settingLockOnMinimize, value)
+}
+
+const settingClipboardClear = "clipboard_clear_seconds"
+
+// DefaultClipboardClear is how long copied secrets stay on the clipboard
+// when no timeout has been configured.
+const DefaultClipboardClear = 30 * time.Second
+
+// ClipboardClearTimeout returns how long copied secrets stay on the
+// clipboard. Zero keeps them until the vault is locked.
+func (db *DB) ClipboardClearTimeout() (time.Duration, error) {
+	seconds, err := db.getIntSetting(settingClipboardClear, int(DefaultClipboardClear/time.Second))
+	return time.Duration(seconds) * time.Second, err
+}
+
+// SetClipboardClearTimeout sets the clipboard timeout, rounded down to
+// whole seconds.
+func (db *DB) SetClipboardClearTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.New("timeout cannot be negative")
+	}
+	return db.SetSetting(settingClipboardClear, strconv.Itoa(int(timeout/time.Second)))
+}
+
+const (
+	settingFailedLoginLimit  = "failed_login_limit"
+	settingFailedLoginAction = "failed_login_action"
+)
+
+// FailedLoginLimit returns after how many consecutive failed logins action
+// is taken. Zero disables it; failed logins are still delayed.
+func (db *DB) FailedLoginLimit() (int, FailureAction, error) {
+	limit, err := db.getIntSetting(settingFailedLoginLimit, 0)
+	if err != nil {
+		return 0, FailureLock, err
+	}
+	action, ok, err := db.GetSetting(settingFailedLoginAction)
+	if err != nil || !ok {
+		return limit, FailureLock, err
+	}
+	return limit, FailureAction(action), nil
+}
+
+func (db *DB) SetFailedLoginLimit(limit int, action FailureAction) error {
+	if limit < 0 {
+		return errors.New("limit cannot be negative")
+	}
+	if action != FailureLock && action != FailureWipe {
+		return fmt.Errorf("unknown failure action %q", action)
+	}
+	return db.WithTx(func(tx *Tx) error {
+		_, err := tx.tx.Exec("INSERT OR REPLACE INTO settings (name, value) VALUES (?, ?), (?, ?)",
+			settingFailedLoginLimit, strconv.Itoa(limit), settingFailedLoginAction, string(action))
+		return err
+	})
+}
@@ -0,0 +1,299 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"spms/crypto"
+	"strings"
+)
+
+type Tag struct {
+	ID   int
+	Name string
+}
+
+const maxTagLength = 64
+
+const tagIndexPurpose = "spms tag index"
+
+// NormalizeTag trims name and checks that it can be used as a tag.
+func NormalizeTag(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("tag cannot be empty")
+	case len(name) > maxTagLength:
+		return "", fmt.Errorf("tag %q is longer than %d characters", name, maxTagLength)
+	case strings.ContainsAny(name, ",\n"):
+		return "", fmt.Errorf("tag %q cannot contain commas or line breaks", name)
+	}
+	return name, nil
+}
+
+// GetTags returns the tags used by entries outside the trash, ordered by
+// name.
+func (db *DB) GetTags(key []byte) ([]Tag, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return queryTags(db.conn, keys,
+		`SELECT DISTINCT t.id, t.name, t.encrypted_name FROM tags t
+		JOIN entry_tags et ON et.tag_id = t.id
+		JOIN passwords p ON p.id = et.entry_id
+		WHERE p.deleted_at IS NULL`)
+}
+
+// GetEntryTags returns the tags of an entry, ordered by name.
+func (db *DB) GetEntryTags(key []byte, entryID int) ([]Tag, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+	return queryTags(db.conn, keys,
+		`SELECT t.id, t.name, t.encrypted_name FROM tags t
+		JOIN entry_tags et ON et.tag_id = t.id
+		WHERE et.entry_id = ?`, entryID)
+}
+
+// TagEntry adds a tag to an entry, creating the tag if needed.
+func (db *DB) TagEntry(key []byte, entryID int, name string) error {
+	return db.WithTx(func(tx *Tx) error {
+		keys, err := loadEntryKeys(tx.tx, key)
+		if err != nil {
+			return err
+		}
+		return tagEntry(tx.tx, keys, entryID, name)
+	})
+}
+
+// UntagEntry removes a tag from an entry.
+func (db *DB) UntagEntry(entryID, tagID int) error {
+	_, err := db.conn.Exec("DELETE FROM entry_tags WHERE entry_id = ? AND tag_id = ?", entryID, tagID)
+	return err
+}
+
+// SetEntryTags replaces the tags of an entry with names.
+func (db *DB) SetEntryTags(key []byte, entryID int, names []string) error {
+	return db.WithTx(func(tx *Tx) error {
+		return tx.SetEntryTags(key, entryID, names)
+	})
+}
+
+func setEntryTags(q querier, key []byte, entryID int, names []string) error {
+	keys, err := loadEntryKeys(q, key)
+	if err != nil {
+		return err
+	}
+	if _, err := q.Exec("DELETE FROM entry_tags WHERE entry_id = ?", entryID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := tagEntry(q, keys, entryID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EntriesWithTag returns the entries outside the trash tagged with tagID.
+func (db *DB) EntriesWithTag(key []byte, tagID int) ([]PasswordEntry, error) {
+	return db.SearchEntries(key, EntryFilter{Tags: []int{tagID}})
+}
+
+// tagAD binds a tag name to its vault and row. Tags belong to no entry, so
+// they have a namespace of their own.
+func (k entryKeys) tagAD(id int) []byte {
+	return []byte(fmt.Sprintf("%x/tag/%d/name", k.vaultID, id))
+}
+
+func (k entryKeys) tagIndex(name string) ([]byte, error) {
+	indexKey, err := crypto.DeriveSubkey(k.key, tagIndexPurpose)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(indexKey)
+	return crypto.BlindIndex(indexKey, name), nil
+}
+
+// tagEntry tags an entry with name. Tags are matched case-insensitively
+// through their blind index. Inserts must run inside a transaction.
+func tagEntry(q querier, keys entryKeys, entryID int, name string) error {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+	index, err := keys.tagIndex(name)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = q.QueryRow("SELECT id FROM tags WHERE name_index = ?", index).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, err = createTag(q, keys, name, index)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	_, err = q.Exec("INSERT OR IGNORE INTO entry_tags (entry_id, tag_id) VALUES (?, ?)", entryID, id)
+	return err
+}
+
+func createTag(q querier, keys entryKeys, name string, index []byte) (int, error) {
+	res, err := q.Exec("INSERT INTO tags (encrypted_name, name_index) VALUES (X'', ?)", index)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), writeTag(q, keys, int(id), name, index)
+}
+
+// writeTag encrypts the name of tag id and clears any plaintext name.
+func writeTag(q querier, keys entryKeys, id int, name string, index []byte) error {
+	encrypted, err := crypto.EncryptWithAlgorithm(keys.cipher, []byte(name), keys.key, keys.tagAD(id))
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("UPDATE tags SET name = NULL, encrypted_name = ?, name_index = ? WHERE id = ?", encrypted, index, id)
+	return err
+}
+
+// tagRow is a tags row as stored. Tags created before names were encrypted
+// keep their plaintext name until the vault is next unlocked.
+type tagRow struct {
+	id            int
+	name          sql.NullString
+	encryptedName []byte
+}
+
+func (r tagRow) isLegacy() bool {
+	return len(r.encryptedName) == 0
+}
+
+func (r tagRow) decrypt(keys entryKeys) (string, error) {
+	if r.isLegacy() {
+		return r.name.String, nil
+	}
+	name, err := crypto.DecryptWithAD(r.encryptedName, keys.key, keys.tagAD(r.id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt tag %d: %w", r.id, err)
+	}
+	return string(name), nil
+}
+
+func queryTagRows(q querier, query string, args ...any) ([]tagRow, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var result []tagRow
+	for rows.Next() {
+		var r tagRow
+		if err := rows.Scan(&r.id, &r.name, &r.encryptedName); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+func queryTags(q querier, keys entryKeys, query string, args ...any) ([]Tag, error) {
+	rows, err := queryTagRows(q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]Tag, 0, len(rows))
+	for _, r := range rows {
+		name, err := r.decrypt(keys)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, Tag{ID: r.id, Name: name})
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+	return tags, nil
+}
+
+// entryTagNames returns the tag names of every entry, keyed by entry ID.
+func entryTagNames(q querier, keys entryKeys) (map[int][]string, error) {
+	tags, err := queryTags(q, keys, "SELECT id, name, encrypted_name FROM tags")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]string, len(tags))
+	for _, t := range tags {
+		byID[t.ID] = t.Name
+	}
+
+	rows, err := q.Query("SELECT entry_id, tag_id FROM entry_tags")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int][]string)
+	for rows.Next() {
+		var entryID, tagID int
+		if err := rows.Scan(&entryID, &tagID); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		names[entryID] = append(names[entryID], byID[tagID])
+	}
+	return names, rows.Err()
+}
+
+// rewriteTags re-encrypts every tag name under newKeys. With legacyOnly,
+// only tags still stored in plaintext are converted.
+func rewriteTags(q querier, oldKeys, newKeys entryKeys, legacyOnly bool) error {
+	rows, err := queryTagRows(q, "SELECT id, name, encrypted_name FROM tags")
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		if legacyOnly && !r.isLegacy() {
+			continue
+		}
+		name, err := r.decrypt(oldKeys)
+		if err != nil {
+			return err
+		}
+		index, err := newKeys.tagIndex(name)
+		if err != nil {
+			return err
+		}
+
+		// Plaintext names were unique under SQLite's ASCII-only NOCASE
+		// collation, so two of them may share a blind index. Merge those.
+		var existing int
+		err = q.QueryRow("SELECT id FROM tags WHERE name_index = ? AND id != ?", index, r.id).Scan(&existing)
+		if err == nil {
+			if _, err := q.Exec("UPDATE OR IGNORE entry_tags SET tag_id = ? WHERE tag_id = ?", existing, r.id); err != nil {
+				return err
+			}
+			if _, err := q.Exec("DELETE FROM tags WHERE id = ?", r.id); err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := writeTag(q, newKeys, r.id, name, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"spms/crypto"
+	"spms/otp"
+)
+
+// SetTOTP stores the authenticator of an entry. secret may be an otpauth://
+// URI or a bare base32 secret and is stored as a normalised URI; an empty
+// secret removes the authenticator.
+func (db *DB) SetTOTP(key []byte, entryID int, secret string) error {
+	return setTOTP(db.conn, key, entryID, secret)
+}
+
+func setTOTP(q querier, key []byte, entryID int, secret string) error {
+	var encrypted []byte
+	if secret != "" {
+		otpKey, err := otp.Parse(secret)
+		if err != nil {
+			return err
+		}
+
+		keys, err := loadEntryKeys(q, key)
+		if err != nil {
+			return err
+		}
+		encrypted, err = keys.seal(entryID, "totp", []byte(otpKey.URI()))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := q.Exec("UPDATE passwords SET encrypted_totp = ? WHERE id = ?", encrypted, entryID)
+	return err
+}
+
+// DecryptTOTP returns the authenticator URI of entry, or "" if it has none.
+func (db *DB) DecryptTOTP(key []byte, entry PasswordEntry) (string, error) {
+	if len(entry.EncryptedTOTP) == 0 {
+		return "", nil
+	}
+
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return "", err
+	}
+	uri, err := keys.open(entry.ID, "totp", entry.EncryptedTOTP)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.ClearBytes(uri)
+	return string(uri), nil
+}
+
+func rewriteTOTP(q querier, row entryRow, oldKeys, newKeys entryKeys) error {
+	if len(row.encryptedTOTP) == 0 {
+		return nil
+	}
+
+	uri, err := oldKeys.open(row.id, "totp", row.encryptedTOTP)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(uri)
+
+	encrypted, err := newKeys.seal(row.id, "totp", uri)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("UPDATE passwords SET encrypted_totp = ? WHERE id = ?", encrypted, row.id)
+	return err
+}
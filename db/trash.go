@@ -0,0 +1,68 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// GetTrash returns the deleted entries with their metadata decrypted.
+func (db *DB) GetTrash(key []byte) ([]PasswordEntry, error) {
+	keys, err := loadEntryKeys(db.conn, key)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryEntryRows(db.conn, "WHERE deleted_at IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	return decryptRows(rows, keys)
+}
+
+// RestoreEntry moves an entry out of the trash.
+func (db *DB) RestoreEntry(id int) error {
+	res, err := db.conn.Exec("UPDATE passwords SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.New("entry is not in the trash")
+	}
+	return nil
+}
+
+// PurgeEntry permanently removes an entry from the trash, together with its
+// fields and history.
+func (db *DB) PurgeEntry(id int) error {
+	res, err := db.conn.Exec("DELETE FROM passwords WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.New("entry is not in the trash")
+	}
+	return nil
+}
+
+// EmptyTrash permanently removes every deleted entry.
+func (db *DB) EmptyTrash() error {
+	_, err := db.conn.Exec("DELETE FROM passwords WHERE deleted_at IS NOT NULL")
+	return err
+}
+
+// PurgeExpired removes entries that have been in the trash for longer than
+// the configured retention and returns how many were removed.
+func (db *DB) PurgeExpired() (int, error) {
+	retention, err := db.TrashRetention()
+	if err != nil || retention == 0 {
+		return 0, err
+	}
+
+	cutoff := time.Now().UTC().Add(-retention).Format("2006-01-02 15:04:05")
+	res, err := db.conn.Exec("DELETE FROM passwords WHERE deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Tx groups vault writes so they are applied atomically.
+type Tx struct {
+	tx *sql.Tx
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{tx: tx}, nil
+}
+
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction. It is a no-op after Commit.
+func (tx *Tx) Rollback() error {
+	err := tx.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise.
+func (db *DB) WithTx(fn func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (tx *Tx) GetAllEntries(key []byte) ([]PasswordEntry, error) {
+	return getAllEntries(tx.tx, key)
+}
+
+func (tx *Tx) SaveMasterKey(mk *MasterKey) error {
+	return saveMasterKey(tx.tx, mk)
+}
+
+func (tx *Tx) AddEntry(key []byte, website, username string, password []byte, notes string, categoryID *int) (int, error) {
+	return addEntry(tx.tx, key, website, username, password, notes, categoryID)
+}
+
+func (tx *Tx) UpdateEntry(key []byte, id int, website, username string, password []byte, notes string, categoryID *int) error {
+	return updateEntry(tx.tx, key, id, website, username, password, notes, categoryID)
+}
+
+func (tx *Tx) SetTOTP(key []byte, entryID int, secret string) error {
+	return setTOTP(tx.tx, key, entryID, secret)
+}
+
+func (tx *Tx) SetEntryTags(key []byte, entryID int, names []string) error {
+	return setEntryTags(tx.tx, key, entryID, names)
+}
+
+func (tx *Tx) SetFields(key []byte, entryID int, fields []CustomField) error {
+	return setFields(tx.tx, key, entryID, fields)
+}
+
+func (tx *Tx) CreateCategory(key []byte, c Category) (int, error) {
+	return createCategory(tx.tx, key, c)
+}
+
+func (tx *Tx) SetFavorite(id int, favorite bool) error {
+	return setFavorite(tx.tx, id, favorite)
+}
+
+// RekeyError reports the entries that could not be re-encrypted. When it is
+// returned the vault has been left unchanged.
+type RekeyError struct {
+	Failed []int
+}
+
+func (e *RekeyError) Error() string {
+	ids := make([]string, len(e.Failed))
+	for i, id := range e.Failed {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("failed to re-encrypt entries %s; vault left unchanged", strings.Join(ids, ", "))
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortOrder selects how SearchEntries orders its result.
+type SortOrder int
+
+const (
+	SortByWebsite SortOrder = iota
+	SortFavoritesFirst
+	SortRecentlyUsed
+	SortFrequentlyUsed
+)
+
+// SortOrders lists the sort orders in display order.
+var SortOrders = []SortOrder{SortByWebsite, SortFavoritesFirst, SortRecentlyUsed, SortFrequentlyUsed}
+
+func (o SortOrder) String() string {
+	switch o {
+	case SortByWebsite:
+		return "Website"
+	case SortFavoritesFirst:
+		return "Favorites first"
+	case SortRecentlyUsed:
+		return "Recently used"
+	case SortFrequentlyUsed:
+		return "Frequently used"
+	}
+	return fmt.Sprintf("SortOrder(%d)", int(o))
+}
+
+// ParseSortOrder returns the sort order with the given name, ignoring case
+// and accepting dashes or underscores for spaces.
+func ParseSortOrder(name string) (SortOrder, error) {
+	normalized := strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	for _, o := range SortOrders {
+		if strings.EqualFold(o.String(), normalized) {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sort order %q", name)
+}
+
+// sortEntries orders entries already sorted by website. Ties keep the
+// website order.
+func sortEntries(entries []PasswordEntry, order SortOrder) {
+	var less func(a, b PasswordEntry) bool
+	switch order {
+	case SortFavoritesFirst:
+		less = func(a, b PasswordEntry) bool {
+			return a.Favorite && !b.Favorite
+		}
+	case SortRecentlyUsed:
+		less = func(a, b PasswordEntry) bool {
+			if a.LastUsedAt == nil || b.LastUsedAt == nil {
+				return a.LastUsedAt != nil && b.LastUsedAt == nil
+			}
+			return a.LastUsedAt.After(*b.LastUsedAt)
+		}
+	case SortFrequentlyUsed:
+		less = func(a, b PasswordEntry) bool {
+			return a.UseCount > b.UseCount
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+}
+
+// SetFavorite marks or unmarks an entry as a favorite.
+func (db *DB) SetFavorite(id int, favorite bool) error {
+	return setFavorite(db.conn, id, favorite)
+}
+
+func setFavorite(q querier, id int, favorite bool) error {
+	_, err := q.Exec("UPDATE passwords SET favorite = ? WHERE id = ?", favorite, id)
+	return err
+}
+
+// MarkUsed records that the password of an entry was copied or revealed.
+func (db *DB) MarkUsed(id int) error {
+	_, err := db.conn.Exec(
+		"UPDATE passwords SET last_used_at = ?, use_count = use_count + 1 WHERE id = ?", time.Now().UTC(), id)
+	return err
+}
@@ -0,0 +1,339 @@
+package db
+
+import (
+	"errors"
+	"spms/crypto"
+)
+
+// ErrInvalidPassword is returned when the master password does not match.
+var ErrInvalidPassword = errors.New("invalid master password")
+
+const vaultIDLength = 16
+
+// InitVault creates a new vault key protected by password and returns it.
+// Entry fields are encrypted with cipher.
+func (db *DB) InitVault(password string, cipher crypto.Algorithm) ([]byte, error) {
+	vaultID, err := crypto.GenerateSecureKey(vaultIDLength)
+	if err != nil {
+		return nil, err
+	}
+
+	vaultKey, err := crypto.GenerateVaultKey()
+	if err != nil {
+		return nil, err
+	}
+
+	mk, err := newMasterKey(password, vaultKey, vaultID, cipher)
+	if err == nil {
+		err = db.SaveMasterKey(mk)
+	}
+	if err != nil {
+		crypto.ClearBytes(vaultKey)
+		return nil, err
+	}
+	return vaultKey, nil
+}
+
+// UnlockVault verifies password and returns the vault key. Failed attempts
+// are logged and delay further attempts; a *LoginDelayError is returned
+// while the delay lasts.
+func (db *DB) UnlockVault(password string) ([]byte, error) {
+	until, err := db.LoginDelay()
+	if err != nil {
+		return nil, err
+	}
+	if !until.IsZero() {
+		return nil, &LoginDelayError{Until: until}
+	}
+
+	key, err := db.unlockVault(password)
+	if errors.Is(err, ErrInvalidPassword) {
+		if err := db.recordFailedLogin(); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.recordLogin(); err != nil {
+		crypto.ClearBytes(key)
+		return nil, err
+	}
+	return key, nil
+}
+
+// VerifyMasterPassword checks password against the vault without unlocking
+// it: no login attempt is recorded and nothing in the vault is changed.
+func (db *DB) VerifyMasterPassword(password string) error {
+	mk, kek, err := db.deriveKEK(password)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(kek)
+
+	if len(mk.WrappedKey) == 0 {
+		return nil
+	}
+	vaultKey, err := crypto.UnwrapKey(mk.WrappedKey, kek)
+	if err != nil {
+		return ErrInvalidPassword
+	}
+	crypto.ClearBytes(vaultKey)
+	return nil
+}
+
+// deriveKEK derives the key-encryption key from password and checks it
+// against the stored master key.
+func (db *DB) deriveKEK(password string) (*MasterKey, []byte, error) {
+	mk, err := db.GetMasterKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if mk == nil {
+		return nil, nil, errors.New("vault is not initialised")
+	}
+
+	kek, err := crypto.DeriveKeyWithParams(password, mk.Salt, mk.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !crypto.VerifyMasterKey(kek, mk.EncryptedCheck) {
+		crypto.ClearBytes(kek)
+		return nil, nil, ErrInvalidPassword
+	}
+	return mk, kek, nil
+}
+
+// unlockVault verifies password and returns the vault key. Vaults created
+// before the key hierarchy existed are migrated to a random vault key.
+func (db *DB) unlockVault(password string) ([]byte, error) {
+	mk, kek, err := db.deriveKEK(password)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(kek)
+	return db.unlockWithKEK(mk, kek)
+}
+
+func (db *DB) unlockWithKEK(mk *MasterKey, kek []byte) ([]byte, error) {
+	// Vaults created before ciphertexts were bound to a vault get an ID
+	// now; it is saved together with the entry upgrade below.
+	var assigned *MasterKey
+	if len(mk.VaultID) == 0 {
+		vaultID, err := crypto.GenerateSecureKey(vaultIDLength)
+		if err != nil {
+			return nil, err
+		}
+		mk.VaultID = vaultID
+		assigned = mk
+	}
+
+	if len(mk.WrappedKey) == 0 {
+		return db.migrateLegacyVault(mk, kek)
+	}
+
+	vaultKey, err := crypto.UnwrapKey(mk.WrappedKey, kek)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	if err := db.upgradeEntries(vaultKey, assigned); err != nil {
+		crypto.ClearBytes(vaultKey)
+		return nil, err
+	}
+	return vaultKey, nil
+}
+
+// RewrapVaultKey protects vaultKey with a key derived from password using
+// the current default KDF parameters.
+func (db *DB) RewrapVaultKey(password string, vaultKey []byte) error {
+	current, err := db.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return errors.New("vault is not initialised")
+	}
+
+	mk, err := newMasterKey(password, vaultKey, current.VaultID, current.Cipher)
+	if err != nil {
+		return err
+	}
+	return db.SaveMasterKey(mk)
+}
+
+// vaultKey checks password and returns the vault key. Unlike UnlockVault it
+// records no login attempt; only vaults that still need migrating to a
+// vault key are upgraded on the way.
+func (db *DB) vaultKey(password string) ([]byte, error) {
+	mk, kek, err := db.deriveKEK(password)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(kek)
+
+	if len(mk.WrappedKey) == 0 || len(mk.VaultID) == 0 {
+		return db.unlockWithKEK(mk, kek)
+	}
+	vaultKey, err := crypto.UnwrapKey(mk.WrappedKey, kek)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return vaultKey, nil
+}
+
+// ChangeMasterPassword re-wraps the vault key under newPassword.
+func (db *DB) ChangeMasterPassword(oldPassword, newPassword string) error {
+	vaultKey, err := db.vaultKey(oldPassword)
+	if err != nil {
+		return err
+	}
+	defer crypto.ClearBytes(vaultKey)
+
+	return db.RewrapVaultKey(newPassword, vaultKey)
+}
+
+// RotateVaultKey changes the master password and re-encrypts every entry
+// with a fresh vault key in a single transaction. It returns the new key.
+func (db *DB) RotateVaultKey(oldPassword, newPassword string) ([]byte, error) {
+	oldKey, err := db.vaultKey(oldPassword)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(oldKey)
+
+	current, err := db.GetMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	newKey, err := crypto.GenerateVaultKey()
+	if err != nil {
+		return nil, err
+	}
+
+	mk, err := newMasterKey(newPassword, newKey, current.VaultID, current.Cipher)
+	if err != nil {
+		crypto.ClearBytes(newKey)
+		return nil, err
+	}
+
+	if err := db.Rekey(oldKey, newKey, mk); err != nil {
+		crypto.ClearBytes(newKey)
+		return nil, err
+	}
+	return newKey, nil
+}
+
+// Rekey re-encrypts every entry from oldKey to newKey and stores mk. Either
+// all changes are committed or none are; entries that fail to convert are
+// reported through a *RekeyError.
+// The vault ID in mk must match the one the entries are bound to.
+func (db *DB) Rekey(oldKey, newKey []byte, mk *MasterKey) error {
+	return db.WithTx(func(tx *Tx) error {
+		oldKeys := entryKeys{key: oldKey, vaultID: mk.VaultID}
+		newKeys := entryKeys{key: newKey, vaultID: mk.VaultID, cipher: mk.Cipher}
+		if err := rewriteEntries(tx.tx, oldKeys, newKeys, false); err != nil {
+			return err
+		}
+		if err := rewriteTags(tx.tx, oldKeys, newKeys, false); err != nil {
+			return err
+		}
+		if err := rewriteCategories(tx.tx, oldKeys, newKeys, false); err != nil {
+			return err
+		}
+		return tx.SaveMasterKey(mk)
+	})
+}
+
+// upgradeEntries converts entries, tags and categories still stored in an older format,
+// saving mk first when it is not nil.
+func (db *DB) upgradeEntries(key []byte, mk *MasterKey) error {
+	return db.WithTx(func(tx *Tx) error {
+		if mk != nil {
+			if err := tx.SaveMasterKey(mk); err != nil {
+				return err
+			}
+		}
+
+		keys, err := loadEntryKeys(tx.tx, key)
+		if err != nil {
+			return err
+		}
+		if err := rewriteEntries(tx.tx, keys, keys, true); err != nil {
+			return err
+		}
+		if err := rewriteTags(tx.tx, keys, keys, true); err != nil {
+			return err
+		}
+		return rewriteCategories(tx.tx, keys, keys, true)
+	})
+}
+
+// ChangeCipher re-encrypts every entry with cipher.
+func (db *DB) ChangeCipher(key []byte, cipher crypto.Algorithm) error {
+	mk, err := db.GetMasterKey()
+	if err != nil {
+		return err
+	}
+	if mk == nil {
+		return errors.New("vault is not initialised")
+	}
+
+	mk.Cipher = cipher
+	return db.Rekey(key, key, mk)
+}
+
+func newMasterKey(password string, vaultKey, vaultID []byte, cipher crypto.Algorithm) (*MasterKey, error) {
+	salt, err := crypto.GenerateSecureKey(int(crypto.DefaultParams.SaltLength))
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := crypto.DeriveKeyWithParams(password, salt, crypto.DefaultParams)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(kek)
+
+	encryptedCheck, err := crypto.GetEncryptedCheck(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := crypto.WrapKey(vaultKey, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MasterKey{
+		Salt:           salt,
+		EncryptedCheck: encryptedCheck,
+		WrappedKey:     wrapped,
+		VaultID:        vaultID,
+		Cipher:         cipher,
+		Params:         crypto.DefaultParams,
+	}, nil
+}
+
+func (db *DB) migrateLegacyVault(mk *MasterKey, kek []byte) ([]byte, error) {
+	vaultKey, err := crypto.GenerateVaultKey()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := crypto.WrapKey(vaultKey, kek)
+	if err != nil {
+		crypto.ClearBytes(vaultKey)
+		return nil, err
+	}
+
+	migrated := *mk
+	migrated.WrappedKey = wrapped
+	if err := db.Rekey(kek, vaultKey, &migrated); err != nil {
+		crypto.ClearBytes(vaultKey)
+		return nil, err
+	}
+	return vaultKey, nil
+}
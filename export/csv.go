@@ -0,0 +1,36 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvHeader = []string{"website", "username", "password", "notes", "category", "tags", "favorite", "totp"}
+
+// WriteCSV writes the entries of v in the CSV format. Categories are
+// written as paths and custom fields are left out.
+func WriteCSV(w io.Writer, v *Vault) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range v.Entries {
+		record := []string{
+			e.Website,
+			e.Username,
+			e.Password,
+			e.Notes,
+			e.Category,
+			strings.Join(e.Tags, ","),
+			strconv.FormatBool(e.Favorite),
+			e.TOTP,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
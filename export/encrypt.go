@@ -0,0 +1,151 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"spms/crypto"
+)
+
+const encryptedFormatName = "spms-export-encrypted"
+
+// Content is the format of the export inside an encrypted file.
+type Content string
+
+const (
+	ContentJSON Content = "json"
+	ContentCSV  Content = "csv"
+)
+
+// Limits on the Argon2 parameters accepted when decrypting, so that a
+// crafted file cannot exhaust memory or run for hours. Memory is in KiB.
+const (
+	maxKDFMemory      = 1 << 20
+	maxKDFIterations  = 16
+	maxKDFParallelism = 16
+)
+
+// MinPassphraseLength is the shortest accepted export passphrase.
+const MinPassphraseLength = 12
+
+// encryptedFile is the JSON document written by Encrypt:
+//
+//	{
+//	  "format": "spms-export-encrypted",
+//	  "version": 1,
+//	  "content": "json",
+//	  "kdf": {"algorithm": "argon2id", "salt": "<base64>", "memory": 131072,
+//	          "iterations": 4, "parallelism": 4},
+//	  "data": "<base64>"
+//	}
+//
+// The key is derived from the passphrase with Argon2id and the given
+// parameters (memory in KiB, 32-byte key). data is 'S' 'P' 0x02 0x01
+// followed by a 12-byte nonce and the AES-256-GCM ciphertext of the
+// export. The associated data is those four header bytes followed by
+// "spms-export-encrypted/1/<content>".
+type encryptedFile struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Content Content   `json:"content"`
+	KDF     kdfParams `json:"kdf"`
+	Data    []byte    `json:"data"`
+}
+
+type kdfParams struct {
+	Algorithm   string `json:"algorithm"`
+	Salt        []byte `json:"salt"`
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+func (f *encryptedFile) ad() []byte {
+	return []byte(fmt.Sprintf("%s/%d/%s", f.Format, f.Version, f.Content))
+}
+
+// Encrypt protects an export of the given content format with passphrase
+// and returns the encrypted file.
+func Encrypt(plaintext []byte, content Content, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("export passphrase must be at least %d characters", MinPassphraseLength)
+	}
+
+	params := crypto.DefaultParams
+	salt, err := crypto.GenerateSecureKey(int(params.SaltLength))
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.DeriveKeyWithParams(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(key)
+
+	f := &encryptedFile{
+		Format:  encryptedFormatName,
+		Version: version,
+		Content: content,
+		KDF: kdfParams{
+			Algorithm:   "argon2id",
+			Salt:        salt,
+			Memory:      params.Memory,
+			Iterations:  params.Iterations,
+			Parallelism: params.Parallelism,
+		},
+	}
+	f.Data, err = crypto.EncryptWithAlgorithm(crypto.AES256GCM, plaintext, key, f.ad())
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// IsEncrypted reports whether data is a file written by Encrypt.
+func IsEncrypted(data []byte) bool {
+	var f struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(data, &f) == nil && f.Format == encryptedFormatName
+}
+
+// Decrypt opens a file written by Encrypt and returns the export and its
+// content format.
+func Decrypt(data []byte, passphrase string) ([]byte, Content, error) {
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, "", fmt.Errorf("invalid encrypted export: %w", err)
+	}
+	if f.Format != encryptedFormatName {
+		return nil, "", errors.New("not an encrypted SPMS export")
+	}
+	if f.Version != version {
+		return nil, "", fmt.Errorf("unsupported export version %d", f.Version)
+	}
+	if f.KDF.Algorithm != "argon2id" {
+		return nil, "", fmt.Errorf("unsupported key derivation %q", f.KDF.Algorithm)
+	}
+
+	// Refuse parameters that would exhaust memory or time before the
+	// passphrase can even be checked.
+	if f.KDF.Memory > maxKDFMemory || f.KDF.Iterations > maxKDFIterations || f.KDF.Parallelism > maxKDFParallelism {
+		return nil, "", errors.New("export key derivation parameters are too expensive")
+	}
+	params := crypto.Argon2Params{
+		Memory:      f.KDF.Memory,
+		Iterations:  f.KDF.Iterations,
+		Parallelism: f.KDF.Parallelism,
+		KeyLength:   32,
+	}
+	key, err := crypto.DeriveKeyWithParams(passphrase, f.KDF.Salt, params)
+	if err != nil {
+		return nil, "", err
+	}
+	defer crypto.ClearBytes(key)
+
+	plaintext, err := crypto.DecryptWithAD(f.Data, key, f.ad())
+	if err != nil {
+		return nil, "", errors.New("wrong passphrase or corrupted export")
+	}
+	return plaintext, f.Content, nil
+}
@@ -0,0 +1,41 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecryptRejectsExpensiveParameters(t *testing.T) {
+	data, err := Encrypt([]byte("{}"), ContentJSON, "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *kdfParams)
+	}{
+		{"memory", func(p *kdfParams) { p.Memory = maxKDFMemory + 1 }},
+		{"iterations", func(p *kdfParams) { p.Iterations = maxKDFIterations + 1 }},
+		{"parallelism", func(p *kdfParams) { p.Parallelism = maxKDFParallelism + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f encryptedFile
+			if err := json.Unmarshal(data, &f); err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(&f.KDF)
+			crafted, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = Decrypt(crafted, "correct horse battery")
+			if err == nil || !strings.Contains(err.Error(), "too expensive") {
+				t.Errorf("Decrypt error = %v, want parameters rejected", err)
+			}
+		})
+	}
+}
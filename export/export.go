@@ -0,0 +1,212 @@
+// Package export writes the contents of a vault to portable files.
+//
+// The JSON format is a single object:
+//
+//	{
+//	  "format": "spms-export",
+//	  "version": 1,
+//	  "exported_at": "2024-01-02T15:04:05Z",
+//	  "categories": [
+//	    {"id": 1, "name": "Work", "parent_id": null, "color": "#3367d6", "icon": "computer"}
+//	  ],
+//	  "entries": [
+//	    {
+//	      "website": "example.com",
+//	      "username": "alice",
+//	      "password": "secret",
+//	      "notes": "",
+//	      "category_id": 1,
+//	      "category": "Work",
+//	      "tags": ["email"],
+//	      "favorite": false,
+//	      "totp": "otpauth://totp/...",
+//	      "fields": [{"type": "pin", "name": "PIN", "value": "1234"}]
+//	    }
+//	  ]
+//	}
+//
+// category is the full path of the category ("Parent / Child") for readers
+// that ignore the categories list. Empty values are omitted. Entries in the
+// trash and password history are not exported.
+//
+// The CSV format has the header row
+//
+//	website,username,password,notes,category,tags,favorite,totp
+//
+// with tags separated by commas inside their column. Custom fields are
+// only kept in the JSON format.
+//
+// Either format can be encrypted with an export passphrase; see Encrypt.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"spms/crypto"
+	"spms/db"
+	"time"
+)
+
+const (
+	formatName = "spms-export"
+	version    = 1
+)
+
+// Vault is the exported contents of a vault.
+type Vault struct {
+	Format     string     `json:"format"`
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Categories []Category `json:"categories"`
+	Entries    []Entry    `json:"entries"`
+}
+
+type Category struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+	Color    string `json:"color,omitempty"`
+	Icon     string `json:"icon,omitempty"`
+}
+
+type Entry struct {
+	Website    string   `json:"website"`
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	Notes      string   `json:"notes,omitempty"`
+	CategoryID *int     `json:"category_id,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Favorite   bool     `json:"favorite,omitempty"`
+	TOTP       string   `json:"totp,omitempty"`
+	Fields     []Field  `json:"fields,omitempty"`
+}
+
+type Field struct {
+	Type  db.FieldType `json:"type"`
+	Name  string       `json:"name"`
+	Value string       `json:"value"`
+}
+
+// Collect decrypts every entry of the vault that is not in the trash.
+func Collect(database *db.DB, key []byte) (*Vault, error) {
+	categories, err := database.GetCategories(key)
+	if err != nil {
+		return nil, err
+	}
+	paths := db.CategoryPaths(categories)
+
+	entries, err := database.GetAllEntries(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{
+		Format:     formatName,
+		Version:    version,
+		ExportedAt: time.Now().UTC(),
+		Categories: make([]Category, 0, len(categories)),
+		Entries:    make([]Entry, 0, len(entries)),
+	}
+	for _, c := range categories {
+		v.Categories = append(v.Categories, Category{
+			ID:       c.ID,
+			Name:     c.Name,
+			ParentID: c.ParentID,
+			Color:    c.Color,
+			Icon:     c.Icon,
+		})
+	}
+
+	for _, entry := range entries {
+		e, err := collectEntry(database, key, entry)
+		if err != nil {
+			return nil, err
+		}
+		if entry.CategoryID != nil {
+			e.Category = paths[*entry.CategoryID]
+		}
+		v.Entries = append(v.Entries, e)
+	}
+	return v, nil
+}
+
+func collectEntry(database *db.DB, key []byte, entry db.PasswordEntry) (Entry, error) {
+	password, err := database.DecryptPassword(key, entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer crypto.ClearBytes(password)
+
+	totp, err := database.DecryptTOTP(key, entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	tags, err := database.GetEntryTags(key, entry.ID)
+	if err != nil {
+		return Entry{}, err
+	}
+	fields, err := database.GetFields(key, entry.ID)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{
+		Website:    entry.Website,
+		Username:   entry.Username,
+		Password:   string(password),
+		Notes:      entry.Notes,
+		CategoryID: entry.CategoryID,
+		Favorite:   entry.Favorite,
+		TOTP:       totp,
+	}
+	for _, t := range tags {
+		e.Tags = append(e.Tags, t.Name)
+	}
+	for _, f := range fields {
+		e.Fields = append(e.Fields, Field{Type: f.Type, Name: f.Name, Value: f.Value})
+	}
+	return e, nil
+}
+
+// WriteJSON writes v in the JSON format.
+func WriteJSON(w io.Writer, v *Vault) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// ReadJSON reads a vault written in the JSON format.
+func ReadJSON(r io.Reader) (*Vault, error) {
+	var v Vault
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid SPMS export: %w", err)
+	}
+	if v.Format != formatName {
+		return nil, errors.New("not an SPMS export")
+	}
+	if v.Version != version {
+		return nil, fmt.Errorf("unsupported export version %d", v.Version)
+	}
+	return &v, nil
+}
+
+// Marshal returns v in the given content format. The result holds
+// plaintext secrets and should be cleared with crypto.ClearBytes.
+func Marshal(v *Vault, content Content) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch content {
+	case ContentJSON:
+		err = WriteJSON(&buf, v)
+	case ContentCSV:
+		err = WriteCSV(&buf, v)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", content)
+	}
+	return buf.Bytes(), err
+}
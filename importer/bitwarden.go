@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"spms/db"
+	"strings"
+)
+
+// Bitwarden item and custom field types.
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+
+	bitwardenFieldHidden = 1
+	bitwardenFieldLinked = 3
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Favorite bool   `json:"favorite"`
+	Login    *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+	Fields []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+}
+
+func parseBitwardenJSON(r io.Reader) (*Result, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid Bitwarden export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, errors.New("encrypted Bitwarden exports are not supported; export as unencrypted JSON")
+	}
+
+	folders := make(map[string]string)
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+
+	res := &Result{}
+	for i, item := range export.Items {
+		rec := Record{
+			Row:      i + 1,
+			Title:    item.Name,
+			Notes:    item.Notes,
+			Favorite: item.Favorite,
+		}
+		if reason := bitwardenUnsupported(item.Type); reason != "" {
+			res.skip(&rec, reason)
+			continue
+		}
+		if name := folders[item.FolderID]; name != "" {
+			rec.Folder = strings.Split(name, "/")
+		}
+		if item.Login != nil {
+			rec.Username = item.Login.Username
+			rec.Password = item.Login.Password
+			rec.TOTP = item.Login.TOTP
+			for j, uri := range item.Login.URIs {
+				if j == 0 {
+					rec.URL = uri.URI
+				} else if uri.URI != "" {
+					rec.Fields = append(rec.Fields, db.CustomField{Type: db.FieldURL, Name: "URL", Value: uri.URI})
+				}
+			}
+		}
+		for _, f := range item.Fields {
+			switch f.Type {
+			case bitwardenFieldLinked:
+				res.warn(&rec, fmt.Sprintf("linked field %q dropped", f.Name))
+			case bitwardenFieldHidden:
+				rec.Fields = append(rec.Fields, db.CustomField{Type: db.FieldHidden, Name: f.Name, Value: f.Value})
+			default:
+				rec.Fields = append(rec.Fields, db.CustomField{Type: db.FieldText, Name: f.Name, Value: f.Value})
+			}
+		}
+		res.add(rec)
+	}
+	return res, nil
+}
+
+// bitwardenUnsupported returns why items of type t cannot be imported, or
+// "" for logins.
+func bitwardenUnsupported(t int) string {
+	switch t {
+	case bitwardenLogin:
+		return ""
+	case bitwardenSecureNote:
+		return "secure notes are not supported"
+	case bitwardenCard:
+		return "cards are not supported"
+	case bitwardenIdentity:
+		return "identities are not supported"
+	}
+	return fmt.Sprintf("item type %d is not supported", t)
+}
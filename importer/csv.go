@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"spms/db"
+	"strings"
+)
+
+// csvFormat maps the columns of a CSV export to record fields.
+type csvFormat struct {
+	name    string
+	columns map[string]string // lower-case header to record field
+	// folderSeparator splits nested folder names.
+	folderSeparator string
+	// adjust fixes up a record read from values, keyed by record field. It
+	// returns why the row should be skipped, or "".
+	adjust func(r *Record, values map[string]string) string
+}
+
+var chromeCSV = csvFormat{
+	name: "Chrome",
+	columns: map[string]string{
+		"name":     "title",
+		"url":      "url",
+		"username": "username",
+		"password": "password",
+		"note":     "notes",
+	},
+}
+
+var lastPassCSV = csvFormat{
+	name: "LastPass",
+	columns: map[string]string{
+		"url":      "url",
+		"username": "username",
+		"password": "password",
+		"totp":     "totp",
+		"extra":    "notes",
+		"name":     "title",
+		"grouping": "folder",
+		"fav":      "favorite",
+	},
+	folderSeparator: `\`,
+	adjust: func(r *Record, values map[string]string) string {
+		// LastPass exports secure notes with this placeholder URL.
+		if values["url"] == "http://sn" {
+			return "secure notes are not supported"
+		}
+		return ""
+	},
+}
+
+var onePasswordCSV = csvFormat{
+	name: "1Password",
+	columns: map[string]string{
+		"title":             "title",
+		"url":               "url",
+		"website":           "url",
+		"username":          "username",
+		"password":          "password",
+		"otpauth":           "totp",
+		"one-time password": "totp",
+		"favorite":          "favorite",
+		"tags":              "tags",
+		"notes":             "notes",
+		"notesplain":        "notes",
+	},
+}
+
+var bitwardenCSV = csvFormat{
+	name: "Bitwarden",
+	columns: map[string]string{
+		"folder":         "folder",
+		"favorite":       "favorite",
+		"type":           "type",
+		"name":           "title",
+		"notes":          "notes",
+		"fields":         "fields",
+		"login_uri":      "url",
+		"login_username": "username",
+		"login_password": "password",
+		"login_totp":     "totp",
+	},
+	folderSeparator: "/",
+	adjust: func(r *Record, values map[string]string) string {
+		if t := values["type"]; t != "" && t != "login" {
+			return fmt.Sprintf("%s items are not supported", t)
+		}
+		// Additional URIs are separated by commas.
+		if uris := strings.Split(r.URL, ","); len(uris) > 1 {
+			r.URL = strings.TrimSpace(uris[0])
+			for _, uri := range uris[1:] {
+				r.Fields = append(r.Fields, db.CustomField{Type: db.FieldURL, Name: "URL", Value: strings.TrimSpace(uri)})
+			}
+		}
+		// Custom fields are written one per line as "name: value".
+		for _, line := range strings.Split(values["fields"], "\n") {
+			name, value, ok := strings.Cut(line, ": ")
+			if ok {
+				r.Fields = append(r.Fields, db.CustomField{Type: db.FieldText, Name: name, Value: value})
+			}
+		}
+		return ""
+	},
+}
+
+func parseCSV(r io.Reader, format csvFormat) (*Result, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("empty %s export", format.name)
+		}
+		return nil, fmt.Errorf("invalid %s export: %w", format.name, err)
+	}
+	columns := make(map[int]string)
+	found := make(map[string]bool)
+	for i, name := range header {
+		if field, ok := format.columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[i] = field
+			found[field] = true
+		}
+	}
+	if !found["password"] {
+		return nil, fmt.Errorf("not a %s export: no password column", format.name)
+	}
+
+	res := &Result{}
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			res.Skipped = append(res.Skipped, Problem{Row: row, Reason: err.Error()})
+			continue
+		}
+		values, ok := rowValues(record, columns)
+		if !ok {
+			continue
+		}
+
+		rec := Record{
+			Row:      row,
+			Title:    values["title"],
+			URL:      values["url"],
+			Username: values["username"],
+			Password: values["password"],
+			Notes:    values["notes"],
+			TOTP:     values["totp"],
+			Favorite: parseBool(values["favorite"]),
+		}
+		if folder := values["folder"]; folder != "" {
+			if format.folderSeparator != "" {
+				rec.Folder = strings.Split(folder, format.folderSeparator)
+			} else {
+				rec.Folder = []string{folder}
+			}
+		}
+		if tags := values["tags"]; tags != "" {
+			rec.Tags = strings.FieldsFunc(tags, func(r rune) bool {
+				return r == ',' || r == ';'
+			})
+		}
+		if format.adjust != nil {
+			if reason := format.adjust(&rec, values); reason != "" {
+				res.skip(&rec, reason)
+				continue
+			}
+		}
+		res.add(rec)
+	}
+	return res, nil
+}
+
+// rowValues keys the values of record by record field. It returns false
+// for rows that are entirely empty.
+func rowValues(record []string, columns map[int]string) (map[string]string, bool) {
+	values := make(map[string]string)
+	empty := true
+	for i, value := range record {
+		if field, ok := columns[i]; ok && values[field] == "" {
+			values[field] = value
+		}
+		if strings.TrimSpace(value) != "" {
+			empty = false
+		}
+	}
+	return values, !empty
+}
+
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
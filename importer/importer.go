@@ -0,0 +1,239 @@
+// Package importer reads the exports of other password managers so their
+// entries can be added to a vault.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"spms/db"
+	"spms/otp"
+	"strings"
+)
+
+// Format identifies the password manager an export comes from.
+type Format string
+
+const (
+	Bitwarden   Format = "bitwarden" // JSON or CSV export
+	KeePass     Format = "keepass"   // KeePass 2 XML export
+	OnePassword Format = "1password" // CSV export
+	LastPass    Format = "lastpass"  // CSV export
+	Chrome      Format = "chrome"    // Chrome or Chromium CSV export
+	SPMS        Format = "spms"      // JSON or CSV export of this program, optionally encrypted
+)
+
+// Formats lists the supported formats in display order.
+var Formats = []Format{Bitwarden, KeePass, OnePassword, LastPass, Chrome, SPMS}
+
+func (f Format) String() string {
+	switch f {
+	case Bitwarden:
+		return "Bitwarden (JSON or CSV)"
+	case KeePass:
+		return "KeePass XML"
+	case OnePassword:
+		return "1Password CSV"
+	case LastPass:
+		return "LastPass CSV"
+	case Chrome:
+		return "Chrome CSV"
+	case SPMS:
+		return "SPMS export"
+	}
+	return string(f)
+}
+
+// Record is an entry read from an export.
+type Record struct {
+	Row      int // position in the export, for messages
+	Title    string
+	URL      string
+	Username string
+	Password string
+	Notes    string
+	Folder   []string // nested folder names, outermost first
+	TOTP     string   // otpauth:// URI or base32 secret
+	Tags     []string
+	Favorite bool
+	Fields   []db.CustomField
+}
+
+// Website returns the name the entry is stored under: its title, or the
+// host of its URL when it has none.
+func (r *Record) Website() string {
+	if r.Title != "" {
+		return r.Title
+	}
+	if u, err := url.Parse(r.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return r.URL
+}
+
+// CategoryPath returns the folder in the "Parent / Child" form used for
+// category paths.
+func (r *Record) CategoryPath() string {
+	return strings.Join(r.Folder, " / ")
+}
+
+// Problem describes a row that was skipped, or a value that was dropped
+// from an imported row.
+type Problem struct {
+	Row    int
+	Title  string
+	Reason string
+}
+
+func (p Problem) String() string {
+	if p.Title == "" {
+		return fmt.Sprintf("row %d: %s", p.Row, p.Reason)
+	}
+	return fmt.Sprintf("row %d (%s): %s", p.Row, p.Title, p.Reason)
+}
+
+// Result holds the records read from an export.
+type Result struct {
+	Records  []Record
+	Skipped  []Problem // rows that cannot be imported
+	Warnings []Problem // values dropped from imported rows
+}
+
+func (res *Result) skip(r *Record, reason string) {
+	res.Skipped = append(res.Skipped, Problem{Row: r.Row, Title: r.Website(), Reason: reason})
+}
+
+func (res *Result) warn(r *Record, reason string) {
+	res.Warnings = append(res.Warnings, Problem{Row: r.Row, Title: r.Website(), Reason: reason})
+}
+
+// add validates r and either keeps it or records why it was skipped.
+func (res *Result) add(r Record) {
+	r.Title = strings.TrimSpace(r.Title)
+	r.URL = strings.TrimSpace(r.URL)
+	r.Username = strings.TrimSpace(r.Username)
+	r.TOTP = strings.TrimSpace(r.TOTP)
+
+	switch {
+	case r.Website() == "":
+		res.skip(&r, "no name or URL")
+		return
+	case r.Password == "":
+		res.skip(&r, "no password")
+		return
+	case r.Username == "":
+		res.skip(&r, "no username")
+		return
+	}
+
+	if r.TOTP != "" {
+		if _, err := otp.Parse(r.TOTP); err != nil {
+			res.warn(&r, fmt.Sprintf("invalid TOTP secret dropped: %v", err))
+			r.TOTP = ""
+		}
+	}
+
+	var tags []string
+	for _, t := range r.Tags {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		name, err := db.NormalizeTag(t)
+		if err != nil {
+			res.warn(&r, err.Error())
+			continue
+		}
+		tags = append(tags, name)
+	}
+	r.Tags = tags
+
+	var folder []string
+	for _, name := range r.Folder {
+		if name = strings.TrimSpace(name); name != "" {
+			folder = append(folder, name)
+		}
+	}
+	r.Folder = folder
+
+	// The URL is kept as a field when the entry is stored under its title.
+	if r.URL != "" && r.Website() != r.URL {
+		fieldType := db.FieldURL
+		if fieldType.Validate(r.URL) != nil {
+			fieldType = db.FieldText
+		}
+		r.Fields = append([]db.CustomField{{Type: fieldType, Name: "URL", Value: r.URL}}, r.Fields...)
+	}
+
+	fields := r.Fields[:0]
+	for _, f := range r.Fields {
+		if f.Name == "" {
+			f.Name = "Field"
+		}
+		if err := f.Type.Validate(f.Value); err != nil {
+			f.Type = db.FieldText
+		}
+		fields = append(fields, f)
+	}
+	r.Fields = fields
+
+	res.Records = append(res.Records, r)
+}
+
+// Parse reads an export in the given format.
+func Parse(format Format, r io.Reader) (*Result, error) {
+	return ParseWithPassphrase(format, r, "")
+}
+
+// ParseWithPassphrase reads an export in the given format, decrypting it
+// with passphrase if it is an encrypted SPMS export.
+func ParseWithPassphrase(format Format, r io.Reader, passphrase string) (*Result, error) {
+	// Strip the byte order mark some tools write before CSV headers.
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+
+	switch format {
+	case Bitwarden:
+		if first, err := firstByte(br); err == nil && first == '{' {
+			return parseBitwardenJSON(br)
+		}
+		return parseCSV(br, bitwardenCSV)
+	case KeePass:
+		return parseKeePass(br)
+	case OnePassword:
+		return parseCSV(br, onePasswordCSV)
+	case LastPass:
+		return parseCSV(br, lastPassCSV)
+	case Chrome:
+		return parseCSV(br, chromeCSV)
+	case SPMS:
+		return parseSPMS(br, passphrase)
+	}
+	return nil, fmt.Errorf("unknown import format %q", format)
+}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown import format %q", name)
+}
+
+// firstByte returns the first non-space byte without consuming it.
+func firstByte(br *bufio.Reader) (byte, error) {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return 0, err
+		}
+		if c := b[i-1]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c, nil
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"spms/db"
+	"strings"
+)
+
+type keePassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+// keePassEntry leaves out the entry's History, which holds old versions
+// of it.
+type keePassEntry struct {
+	Tags    string `xml:"Tags"`
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value struct {
+			Text            string `xml:",chardata"`
+			Protected       bool   `xml:"Protected,attr"`
+			ProtectInMemory bool   `xml:"ProtectInMemory,attr"`
+		} `xml:"Value"`
+	} `xml:"String"`
+}
+
+// errKeePassProtected is returned for the XML inside a KeePass database,
+// whose protected values are still encrypted.
+var errKeePassProtected = errors.New("protected values are encrypted; export the database as KeePass XML (2.x)")
+
+func parseKeePass(r io.Reader) (*Result, error) {
+	var file keePassFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid KeePass export: %w", err)
+	}
+	if len(file.Root.Groups) == 0 {
+		return nil, errors.New("not a KeePass XML export: no root group")
+	}
+
+	p := &keePassParser{res: &Result{}, recycleBin: file.Meta.RecycleBinUUID}
+	// The root group is the database itself rather than a folder.
+	for _, root := range file.Root.Groups {
+		if err := p.group(root, nil); err != nil {
+			return nil, err
+		}
+	}
+	return p.res, nil
+}
+
+type keePassParser struct {
+	res        *Result
+	recycleBin string
+	row        int
+}
+
+// group reads the entries of g and its subgroups. folder is the path of g
+// below the root group.
+func (p *keePassParser) group(g keePassGroup, folder []string) error {
+	for _, e := range g.Entries {
+		p.row++
+		rec, err := p.entry(e, folder)
+		if err != nil {
+			return err
+		}
+		p.res.add(rec)
+	}
+	for _, sub := range g.Groups {
+		if p.recycleBin != "" && sub.UUID == p.recycleBin {
+			continue
+		}
+		path := append(append([]string(nil), folder...), sub.Name)
+		if err := p.group(sub, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keePassTOTP holds the TOTP settings KeePass 2.47+ stores as separate
+// TimeOtp-* strings.
+type keePassTOTP struct {
+	secret, period, length, algorithm string
+}
+
+// uri returns the settings as an otpauth:// URI. Invalid values are kept
+// for Result.add to report.
+func (t keePassTOTP) uri(issuer, account string) string {
+	q := url.Values{}
+	q.Set("secret", t.secret)
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	if t.algorithm != "" {
+		// KeePass names them HMAC-SHA-1, HMAC-SHA-256 and HMAC-SHA-512.
+		alg := strings.TrimPrefix(strings.ToUpper(t.algorithm), "HMAC-")
+		q.Set("algorithm", strings.ReplaceAll(alg, "-", ""))
+	}
+	if t.length != "" {
+		q.Set("digits", t.length)
+	}
+	if t.period != "" {
+		q.Set("period", t.period)
+	}
+
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+func (p *keePassParser) entry(e keePassEntry, folder []string) (Record, error) {
+	rec := Record{
+		Row:    p.row,
+		Folder: folder,
+		Tags: strings.FieldsFunc(e.Tags, func(r rune) bool {
+			return r == ';' || r == ','
+		}),
+	}
+	var totp keePassTOTP
+	for _, s := range e.Strings {
+		if s.Value.Protected {
+			return rec, errKeePassProtected
+		}
+		value := s.Value.Text
+		switch s.Key {
+		case "Title":
+			rec.Title = value
+		case "UserName":
+			rec.Username = value
+		case "Password":
+			rec.Password = value
+		case "URL":
+			rec.URL = value
+		case "Notes":
+			rec.Notes = value
+		case "otp":
+			// KeePassXC stores an otpauth:// URI.
+			rec.TOTP = value
+		case "TimeOtp-Secret-Base32":
+			totp.secret = strings.TrimSpace(value)
+		case "TimeOtp-Period":
+			totp.period = strings.TrimSpace(value)
+		case "TimeOtp-Length":
+			totp.length = strings.TrimSpace(value)
+		case "TimeOtp-Algorithm":
+			totp.algorithm = strings.TrimSpace(value)
+		default:
+			if strings.HasPrefix(s.Key, "TimeOtp-") || value == "" {
+				continue
+			}
+			fieldType := db.FieldText
+			if s.Value.ProtectInMemory {
+				fieldType = db.FieldHidden
+			}
+			rec.Fields = append(rec.Fields, db.CustomField{Type: fieldType, Name: s.Key, Value: value})
+		}
+	}
+	if rec.TOTP == "" && totp.secret != "" {
+		rec.TOTP = totp.uri(rec.Title, rec.Username)
+	}
+	return rec, nil
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"spms/crypto"
+	"spms/db"
+	"spms/export"
+	"strings"
+)
+
+// ErrPassphraseRequired is returned for an encrypted SPMS export when no
+// export passphrase was given.
+var ErrPassphraseRequired = errors.New("the export is encrypted; enter its export passphrase")
+
+var spmsCSV = csvFormat{
+	name: "SPMS",
+	columns: map[string]string{
+		"website":  "title",
+		"username": "username",
+		"password": "password",
+		"notes":    "notes",
+		"category": "folder",
+		"tags":     "tags",
+		"favorite": "favorite",
+		"totp":     "totp",
+	},
+	folderSeparator: " / ",
+}
+
+// parseSPMS reads an export written by this program, decrypting it with
+// passphrase when it is encrypted.
+func parseSPMS(r io.Reader, passphrase string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !export.IsEncrypted(data) {
+		return parseSPMSContent(data)
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	plaintext, content, err := export.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.ClearBytes(plaintext)
+	if content == export.ContentCSV {
+		return parseCSV(bytes.NewReader(plaintext), spmsCSV)
+	}
+	return parseSPMSJSON(bytes.NewReader(plaintext))
+}
+
+func parseSPMSContent(data []byte) (*Result, error) {
+	br := bufio.NewReader(bytes.NewReader(data))
+	if first, err := firstByte(br); err == nil && first == '{' {
+		return parseSPMSJSON(br)
+	}
+	return parseCSV(br, spmsCSV)
+}
+
+func parseSPMSJSON(r io.Reader) (*Result, error) {
+	v, err := export.ReadJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Category names may contain the path separator, so folders are built
+	// from the categories list rather than by splitting the path.
+	categories := make([]db.Category, len(v.Categories))
+	for i, c := range v.Categories {
+		categories[i] = db.Category{ID: c.ID, Name: c.Name, ParentID: c.ParentID}
+	}
+	folders := categoryFolders(categories)
+
+	res := &Result{}
+	for i, e := range v.Entries {
+		rec := Record{
+			Row:      i + 1,
+			Title:    e.Website,
+			Username: e.Username,
+			Password: e.Password,
+			Notes:    e.Notes,
+			TOTP:     e.TOTP,
+			Tags:     e.Tags,
+			Favorite: e.Favorite,
+		}
+		if e.CategoryID != nil {
+			rec.Folder = folders[*e.CategoryID]
+		}
+		if rec.Folder == nil && e.Category != "" {
+			rec.Folder = strings.Split(e.Category, " / ")
+		}
+		for _, f := range e.Fields {
+			rec.Fields = append(rec.Fields, db.CustomField{Type: f.Type, Name: f.Name, Value: f.Value})
+		}
+		res.add(rec)
+	}
+	return res, nil
+}
+
+// categoryFolders returns the names from the top-level category down to
+// each category, keyed by ID.
+func categoryFolders(categories []db.Category) map[int][]string {
+	byID := make(map[int]db.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	folders := make(map[int][]string, len(categories))
+	for _, c := range categories {
+		names := []string{c.Name}
+		for parent := c.ParentID; parent != nil && len(names) <= len(categories); {
+			p, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			names = append([]string{p.Name}, names...)
+			parent = p.ParentID
+		}
+		folders[c.ID] = names
+	}
+	return folders
+}
@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"spms/db"
+	"spms/export"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+func testVault() *export.Vault {
+	parent, child := 1, 2
+	return &export.Vault{
+		Format:  "spms-export",
+		Version: 1,
+		Categories: []export.Category{
+			{ID: parent, Name: "Work"},
+			{ID: child, Name: "CI / CD", ParentID: &parent},
+		},
+		Entries: []export.Entry{
+			{
+				Website:    "example.com",
+				Username:   "alice",
+				Password:   "hunter2",
+				Notes:      "first line\nsecond line",
+				CategoryID: &child,
+				Category:   "Work / CI / CD",
+				Tags:       []string{"email", "shared"},
+				Favorite:   true,
+				TOTP:       "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP",
+				Fields:     []export.Field{{Type: db.FieldPIN, Name: "PIN", Value: "1234"}},
+			},
+			{Website: "plain.org", Username: "bob", Password: "swordfish"},
+		},
+	}
+}
+
+func TestEncryptedExportRoundTrip(t *testing.T) {
+	want := []Record{
+		{
+			Row:      1,
+			Title:    "example.com",
+			Username: "alice",
+			Password: "hunter2",
+			Notes:    "first line\nsecond line",
+			Folder:   []string{"Work", "CI / CD"},
+			TOTP:     "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP",
+			Tags:     []string{"email", "shared"},
+			Favorite: true,
+			Fields:   []db.CustomField{{Type: db.FieldPIN, Name: "PIN", Value: "1234"}},
+		},
+		{Row: 2, Title: "plain.org", Username: "bob", Password: "swordfish"},
+	}
+
+	plaintext, err := export.Marshal(testVault(), export.ContentJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := export.Encrypt(plaintext, export.ContentJSON, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(SPMS, bytes.NewReader(data)); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Parse without passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+	if _, err := ParseWithPassphrase(SPMS, bytes.NewReader(data), "wrong passphrase"); err == nil {
+		t.Error("ParseWithPassphrase succeeded with the wrong passphrase")
+	}
+
+	res, err := ParseWithPassphrase(SPMS, bytes.NewReader(data), testPassphrase)
+	if err != nil {
+		t.Fatalf("ParseWithPassphrase: %v", err)
+	}
+	if len(res.Skipped) > 0 || len(res.Warnings) > 0 {
+		t.Errorf("skipped %v, warnings %v", res.Skipped, res.Warnings)
+	}
+	if !reflect.DeepEqual(res.Records, want) {
+		t.Errorf("records = %+v\nwant %+v", res.Records, want)
+	}
+}
+
+func TestEncryptedCSVExportRoundTrip(t *testing.T) {
+	plaintext, err := export.Marshal(testVault(), export.ContentCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := export.Encrypt(plaintext, export.ContentCSV, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ParseWithPassphrase(SPMS, bytes.NewReader(data), testPassphrase)
+	if err != nil {
+		t.Fatalf("ParseWithPassphrase: %v", err)
+	}
+	if len(res.Records) != 2 {
+		t.Fatalf("got %d records, want 2", len(res.Records))
+	}
+	r := res.Records[0]
+	if r.Title != "example.com" || r.Password != "hunter2" || !r.Favorite ||
+		!reflect.DeepEqual(r.Tags, []string{"email", "shared"}) {
+		t.Errorf("record = %+v", r)
+	}
+}
@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"spms/crypto"
+	"spms/db"
+	"strings"
+)
+
+type entryKey struct {
+	website, username, password string
+}
+
+func recordKey(r *Record) entryKey {
+	return entryKey{strings.ToLower(r.Website()), r.Username, r.Password}
+}
+
+// FindDuplicates reports which records repeat an entry already in the
+// vault, or an earlier record, with the same website, username and
+// password. Existing entries are looked up by website, so only those
+// sharing a website with a record are decrypted.
+func FindDuplicates(database *db.DB, key []byte, records []Record) ([]bool, error) {
+	seen := make(map[entryKey]bool, len(records))
+	looked := make(map[string]bool, len(records))
+	duplicates := make([]bool, len(records))
+	for i := range records {
+		website := strings.ToLower(records[i].Website())
+		if !looked[website] {
+			looked[website] = true
+			if err := addExisting(database, key, records[i].Website(), seen); err != nil {
+				return nil, err
+			}
+		}
+
+		k := recordKey(&records[i])
+		duplicates[i] = seen[k]
+		seen[k] = true
+	}
+	return duplicates, nil
+}
+
+// addExisting marks the vault entries for website as seen.
+func addExisting(database *db.DB, key []byte, website string, seen map[entryKey]bool) error {
+	entries, err := database.FindByWebsite(key, website)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		password, err := database.DecryptPassword(key, entry)
+		if err != nil {
+			return err
+		}
+		seen[entryKey{strings.ToLower(entry.Website), entry.Username, string(password)}] = true
+		crypto.ClearBytes(password)
+	}
+	return nil
+}
+
+// Import adds records to the vault, creating the categories their folders
+// name. Each record is written in its own transaction, so a record that
+// fails leaves neither an entry nor new categories behind. It returns how
+// many were added and the records that failed.
+func Import(database *db.DB, key []byte, records []Record) (int, []Problem, error) {
+	categories, err := newCategoryResolver(database, key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var added int
+	var failed []Problem
+	for i := range records {
+		r := &records[i]
+		err := database.WithTx(func(tx *db.Tx) error {
+			return importRecord(tx, key, r, categories)
+		})
+		if err != nil {
+			categories.discard()
+			failed = append(failed, Problem{Row: r.Row, Title: r.Website(), Reason: err.Error()})
+			continue
+		}
+		categories.keep()
+		added++
+	}
+	return added, failed, nil
+}
+
+// importRecord adds r to the vault.
+func importRecord(tx *db.Tx, key []byte, r *Record, categories *categoryResolver) error {
+	categoryID, err := categories.resolve(tx, key, r.Folder)
+	if err != nil {
+		return err
+	}
+	id, err := tx.AddEntry(key, r.Website(), r.Username, []byte(r.Password), r.Notes, categoryID)
+	if err != nil {
+		return err
+	}
+	if r.TOTP != "" {
+		if err := tx.SetTOTP(key, id, r.TOTP); err != nil {
+			return err
+		}
+	}
+	if len(r.Tags) > 0 {
+		if err := tx.SetEntryTags(key, id, r.Tags); err != nil {
+			return err
+		}
+	}
+	if len(r.Fields) > 0 {
+		if err := tx.SetFields(key, id, r.Fields); err != nil {
+			return err
+		}
+	}
+	if r.Favorite {
+		return tx.SetFavorite(id, true)
+	}
+	return nil
+}
+
+// categoryResolver finds or creates the category for a folder path.
+// Categories created for a record are pending until its transaction is
+// committed.
+type categoryResolver struct {
+	byPath  map[string]int // "Parent / Child" path to category ID
+	pending map[string]int
+}
+
+func newCategoryResolver(database *db.DB, key []byte) (*categoryResolver, error) {
+	categories, err := database.GetCategories(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &categoryResolver{byPath: make(map[string]int), pending: make(map[string]int)}
+	for id, path := range db.CategoryPaths(categories) {
+		c.byPath[path] = id
+	}
+	return c, nil
+}
+
+// resolve returns the category for folder, creating any missing levels.
+func (c *categoryResolver) resolve(tx *db.Tx, key []byte, folder []string) (*int, error) {
+	var parent *int
+	for i, name := range folder {
+		path := strings.Join(folder[:i+1], " / ")
+		id, ok := c.byPath[path]
+		if !ok {
+			id, ok = c.pending[path]
+		}
+		if !ok {
+			var err error
+			id, err = tx.CreateCategory(key, db.Category{Name: name, ParentID: parent})
+			if err != nil {
+				return nil, err
+			}
+			c.pending[path] = id
+		}
+		parent = &id
+	}
+	return parent, nil
+}
+
+// keep records the pending categories once their transaction is committed.
+func (c *categoryResolver) keep() {
+	for path, id := range c.pending {
+		c.byPath[path] = id
+	}
+	clear(c.pending)
+}
+
+// discard forgets the pending categories after their transaction was
+// rolled back.
+func (c *categoryResolver) discard() {
+	clear(c.pending)
+}
@@ -1,24 +1,40 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"spms/db"
+	"os"
+	"spms/cli"
+	"spms/crypto"
 	"spms/ui"
+	"spms/vaults"
 
 	"fyne.io/fyne/v2/app"
 )
 
 func main() {
-	myApp := app.New()
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		os.Exit(cli.Run(os.Args[2:]))
+	}
 
-	database, err := db.NewDB("vault.db")
+	vault := flag.String("vault", "", "vault name or path (default $"+vaults.EnvVault+" or the default vault)")
+	flag.Parse()
+
+	vaultPath, err := vaults.Resolve(*vault)
 	if err != nil {
-		log.Fatal("Database initialization failed:", err)
+		log.Fatal("Failed to locate vault:", err)
 	}
-	defer database.Close()
 
-	loginWindow := ui.CreateLoginWindow(myApp, database)
-	loginWindow.Show()
+	myApp := app.New()
+
+	session := crypto.NewSession()
+	defer session.Lock()
+
+	if legacy, ok := vaults.FindLegacy(*vault); ok {
+		ui.CreateLegacyVaultWindow(myApp, legacy, vaultPath, session).Show()
+	} else {
+		ui.CreateLoginWindow(myApp, vaultPath, session).Show()
+	}
 
 	myApp.Run()
 }
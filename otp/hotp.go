@@ -0,0 +1,59 @@
+// Package otp implements HMAC-based (RFC 4226) and time-based (RFC 6238)
+// one-time passwords.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Algorithm is the HMAC hash used to compute codes.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch Algorithm(strings.ToUpper(string(a))) {
+	case SHA1, "":
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", string(a))
+}
+
+var powers = [...]uint32{1e6, 1e7, 1e8}
+
+// HOTP computes the RFC 4226 code for counter with the given number of
+// digits (6 to 8).
+func HOTP(secret []byte, counter uint64, digits int, alg Algorithm) (string, error) {
+	if digits < 6 || digits > 8 {
+		return "", fmt.Errorf("digits must be between 6 and 8, got %d", digits)
+	}
+	newHash, err := alg.hash()
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(newHash, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, code%powers[digits-6]), nil
+}
@@ -0,0 +1,33 @@
+package otp
+
+import "testing"
+
+// RFC 4226, Appendix D.
+func TestHOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for counter, code := range want {
+		got, err := HOTP(secret, uint64(counter), 6, SHA1)
+		if err != nil {
+			t.Fatalf("HOTP(%d): %v", counter, err)
+		}
+		if got != code {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestHOTPRejectsInvalidParameters(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for _, digits := range []int{5, 9} {
+		if _, err := HOTP(secret, 0, digits, SHA1); err == nil {
+			t.Errorf("HOTP accepted %d digits", digits)
+		}
+	}
+	if _, err := HOTP(secret, 0, 6, "MD5"); err == nil {
+		t.Error("HOTP accepted algorithm MD5")
+	}
+}
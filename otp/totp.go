@@ -0,0 +1,70 @@
+package otp
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+// Key holds the parameters of a TOTP generator.
+type Key struct {
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	Period    int // seconds
+	Issuer    string
+	Account   string
+}
+
+// NewKey returns a key with the RFC 6238 defaults for secret.
+func NewKey(secret []byte) *Key {
+	return &Key{
+		Secret:    secret,
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+}
+
+// Code returns the TOTP code valid at t.
+func (k *Key) Code(t time.Time) (string, error) {
+	if k.Period <= 0 {
+		return "", errors.New("period must be positive")
+	}
+	return HOTP(k.Secret, uint64(t.Unix())/uint64(k.Period), k.Digits, k.Algorithm)
+}
+
+// Remaining returns how long the code valid at t stays valid.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period)
+	if period <= 0 {
+		return 0
+	}
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// DecodeSecret decodes a base32 secret as shown by most providers,
+// ignoring spaces, dashes, case and missing padding.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(s))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, errors.New("secret cannot be empty")
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, errors.New("secret is not valid base32")
+	}
+	return secret, nil
+}
+
+// EncodeSecret encodes secret as unpadded base32.
+func EncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
@@ -0,0 +1,78 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238, Appendix B. Each algorithm uses the test seed repeated to the
+// length of its hash output.
+func TestTOTPVectors(t *testing.T) {
+	seeds := map[Algorithm][]byte{
+		SHA1:   []byte("12345678901234567890"),
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix int64
+		alg  Algorithm
+		code string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1111111109, SHA512, "25091201"},
+		{1111111111, SHA1, "14050471"},
+		{1111111111, SHA256, "67062674"},
+		{1111111111, SHA512, "99943326"},
+		{1234567890, SHA1, "89005924"},
+		{1234567890, SHA256, "91819424"},
+		{1234567890, SHA512, "93441116"},
+		{2000000000, SHA1, "69279037"},
+		{2000000000, SHA256, "90698825"},
+		{2000000000, SHA512, "38618901"},
+		{20000000000, SHA1, "65353130"},
+		{20000000000, SHA256, "77737706"},
+		{20000000000, SHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		key := NewKey(seeds[tt.alg])
+		key.Algorithm = tt.alg
+		key.Digits = 8
+
+		got, err := key.Code(time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("%s at %d: %v", tt.alg, tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("%s at %d = %s, want %s", tt.alg, tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := NewKey([]byte("12345678901234567890"))
+	if got := key.Remaining(time.Unix(59, 0)); got != time.Second {
+		t.Errorf("Remaining at 59 = %v, want 1s", got)
+	}
+	if got := key.Remaining(time.Unix(60, 0)); got != 30*time.Second {
+		t.Errorf("Remaining at 60 = %v, want 30s", got)
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	got, err := DecodeSecret("gezd gnbv-gy3t qojq gezd gnbv gy3t qojq====")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "12345678901234567890" {
+		t.Errorf("DecodeSecret = %q", got)
+	}
+	for _, s := range []string{"", "   ", "not base32!"} {
+		if _, err := DecodeSecret(s); err == nil {
+			t.Errorf("DecodeSecret(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseURI parses an otpauth://totp/ URI as produced by authenticator QR
+// codes.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "otpauth" {
+		return nil, errors.New("not an otpauth URI")
+	}
+	if u.Host != "totp" {
+		return nil, fmt.Errorf("unsupported otp type %q", u.Host)
+	}
+
+	q := u.Query()
+	secret, err := DecodeSecret(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+	key := NewKey(secret)
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer = strings.TrimSpace(issuer)
+		key.Account = strings.TrimSpace(account)
+	} else {
+		key.Account = label
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+
+	if alg := q.Get("algorithm"); alg != "" {
+		key.Algorithm = Algorithm(strings.ToUpper(alg))
+		if _, err := key.Algorithm.hash(); err != nil {
+			return nil, err
+		}
+	}
+	if digits := q.Get("digits"); digits != "" {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil || key.Digits < 6 || key.Digits > 8 {
+			return nil, fmt.Errorf("invalid digits %q", digits)
+		}
+	}
+	if period := q.Get("period"); period != "" {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil || key.Period <= 0 {
+			return nil, fmt.Errorf("invalid period %q", period)
+		}
+	}
+	return key, nil
+}
+
+// Parse accepts either an otpauth:// URI or a bare base32 secret.
+func Parse(s string) (*Key, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "otpauth://") {
+		return ParseURI(s)
+	}
+	secret, err := DecodeSecret(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(secret), nil
+}
+
+// URI formats the key as an otpauth://totp/ URI.
+func (k *Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(k.Secret))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", string(k.Algorithm))
+	q.Set("digits", strconv.Itoa(k.Digits))
+	q.Set("period", strconv.Itoa(k.Period))
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
@@ -0,0 +1,103 @@
+package otp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		name string
+		uri  string
+		want *Key
+	}{
+		{
+			name: "defaults",
+			uri:  "otpauth://totp/alice@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			want: &Key{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30, Account: "alice@example.com"},
+		},
+		{
+			name: "issuer in label",
+			uri:  "otpauth://totp/Example:alice@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			want: &Key{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30, Issuer: "Example", Account: "alice@example.com"},
+		},
+		{
+			name: "issuer parameter wins",
+			uri:  "otpauth://totp/Old:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=New",
+			want: &Key{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30, Issuer: "New", Account: "alice"},
+		},
+		{
+			name: "all parameters",
+			uri:  "otpauth://totp/ACME%20Co:john?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq&algorithm=sha256&digits=8&period=60",
+			want: &Key{Secret: secret, Algorithm: SHA256, Digits: 8, Period: 60, Issuer: "ACME Co", Account: "john"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseURI(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseURI = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseURIRejectsInvalid(t *testing.T) {
+	const secret = "secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"wrong scheme", "https://totp/alice?" + secret},
+		{"hotp", "otpauth://hotp/alice?" + secret + "&counter=0"},
+		{"missing secret", "otpauth://totp/alice"},
+		{"bad secret", "otpauth://totp/alice?secret=not-base32!"},
+		{"zero period", "otpauth://totp/alice?" + secret + "&period=0"},
+		{"negative period", "otpauth://totp/alice?" + secret + "&period=-30"},
+		{"non-numeric period", "otpauth://totp/alice?" + secret + "&period=thirty"},
+		{"too few digits", "otpauth://totp/alice?" + secret + "&digits=5"},
+		{"too many digits", "otpauth://totp/alice?" + secret + "&digits=9"},
+		{"non-numeric digits", "otpauth://totp/alice?" + secret + "&digits=six"},
+		{"unknown algorithm", "otpauth://totp/alice?" + secret + "&algorithm=MD5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key, err := ParseURI(tt.uri); err == nil {
+				t.Errorf("ParseURI succeeded: %+v", key)
+			}
+		})
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	key := &Key{
+		Secret:    []byte("12345678901234567890"),
+		Algorithm: SHA512,
+		Digits:    8,
+		Period:    60,
+		Issuer:    "Example",
+		Account:   "alice@example.com",
+	}
+	got, err := Parse(key.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, key) {
+		t.Errorf("Parse(URI()) = %+v, want %+v", got, key)
+	}
+}
+
+func TestParseBareSecret(t *testing.T) {
+	got, err := Parse(" GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewKey([]byte("12345678901234567890"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"fmt"
+	"spms/db"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// autoLockOptions are the inactivity timeouts offered, in minutes. Zero
+// never locks on inactivity.
+var autoLockOptions = []int{0, 1, 5, 15, 30, 60}
+
+func autoLockLabel(minutes int) string {
+	switch minutes {
+	case 0:
+		return "Never"
+	case 1:
+		return "After 1 minute"
+	}
+	return fmt.Sprintf("After %d minutes", minutes)
+}
+
+// idleTimer calls onIdle once no activity has been reported for timeout.
+// onIdle runs on its own goroutine.
+type idleTimer struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	timer   *time.Timer
+	onIdle  func()
+	stopped bool
+}
+
+func newIdleTimer(timeout time.Duration, onIdle func()) *idleTimer {
+	t := &idleTimer{onIdle: onIdle}
+	t.SetTimeout(timeout)
+	return t
+}
+
+// Reset restarts the countdown after user activity.
+func (t *idleTimer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+// SetTimeout changes the timeout and restarts the countdown. Zero disables
+// the timer.
+func (t *idleTimer) SetTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.timeout = timeout
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, t.onIdle)
+	}
+}
+
+// Stop disables the timer for good.
+func (t *idleTimer) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// activityMonitor wraps content and reports pointer movement over it. Fyne
+// only delivers hover events to the innermost hoverable object, so
+// movement over buttons and list rows is seen as the pointer entering and
+// leaving them.
+type activityMonitor struct {
+	widget.BaseWidget
+	content    fyne.CanvasObject
+	onActivity func()
+}
+
+func newActivityMonitor(content fyne.CanvasObject, onActivity func()) *activityMonitor {
+	m := &activityMonitor{content: content, onActivity: onActivity}
+	m.ExtendBaseWidget(m)
+	return m
+}
+
+func (m *activityMonitor) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(m.content)
+}
+
+func (m *activityMonitor) MouseIn(*desktop.MouseEvent) {
+	m.onActivity()
+}
+
+func (m *activityMonitor) MouseMoved(*desktop.MouseEvent) {
+	m.onActivity()
+}
+
+func (m *activityMonitor) MouseOut() {
+	m.onActivity()
+}
+
+// startAutoLock locks the vault after the configured inactivity timeout,
+// on Ctrl+L and, if enabled, when the app leaves the foreground. Fyne has
+// no minimize event, so losing focus to another app counts as well.
+func (mw *MainWindow) startAutoLock() {
+	timeout, err := mw.db.AutoLockTimeout()
+	if err != nil {
+		timeout = db.DefaultAutoLock
+	}
+	mw.idle = newIdleTimer(timeout, func() {
+		fyne.Do(mw.lock)
+	})
+
+	// Key events only reach the canvas when no widget has focus; typing
+	// into entries is reported through watchInput.
+	canvas := mw.window.Canvas()
+	canvas.SetOnTypedRune(func(rune) {
+		mw.touch()
+	})
+	canvas.SetOnTypedKey(func(*fyne.KeyEvent) {
+		mw.touch()
+	})
+	canvas.AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyL, Modifier: fyne.KeyModifierShortcutDefault},
+		func(fyne.Shortcut) {
+			mw.lock()
+		})
+
+	// The login window losing focus fires OnExitedForeground before the
+	// main window is focused, so only lock once the main window has been
+	// in the foreground.
+	lifecycle := mw.app.Lifecycle()
+	lifecycle.SetOnEnteredForeground(func() {
+		mw.foreground = true
+	})
+	lifecycle.SetOnExitedForeground(func() {
+		if !mw.foreground {
+			return
+		}
+		mw.foreground = false
+		if enabled, err := mw.db.LockOnMinimize(); err == nil && enabled {
+			mw.lock()
+		}
+	})
+
+	// Closing the window is the last point at which the clipboard can be
+	// reached on exit; the driver is already gone once OnStopped runs.
+	mw.window.SetOnClosed(func() {
+		mw.clipboard.Clear()
+		mw.idle.Stop()
+		lifecycle.SetOnEnteredForeground(nil)
+		lifecycle.SetOnExitedForeground(nil)
+	})
+}
+
+// touch records user activity.
+func (mw *MainWindow) touch() {
+	mw.foreground = true
+	if mw.idle != nil {
+		mw.idle.Reset()
+	}
+}
+
+// watchInput resets the idle timer whenever one of entries is edited. It
+// must be called after their own OnChanged handlers are set.
+func (mw *MainWindow) watchInput(entries ...*widget.Entry) {
+	notifyOnInput(mw.touch, entries...)
+}
+
+// notifyOnInput calls notify, then the entry's own handler, whenever one of
+// entries changes. A nil notify leaves the entries unchanged.
+func notifyOnInput(notify func(), entries ...*widget.Entry) {
+	if notify == nil {
+		return
+	}
+	for _, entry := range entries {
+		onChanged := entry.OnChanged
+		entry.OnChanged = func(text string) {
+			notify()
+			if onChanged != nil {
+				onChanged(text)
+			}
+		}
+	}
+}
+
+// lock wipes the session key, hides dialogs showing secrets and replaces
+// the main window with a login window for the same vault.
+func (mw *MainWindow) lock() {
+	if mw.locked {
+		return
+	}
+	mw.locked = true
+
+	for d := range mw.secrets {
+		d.Hide()
+	}
+	mw.session.Lock()
+	mw.entries.Clear()
+	mw.trash.Clear()
+
+	login := CreateLoginWindow(mw.app, mw.vaultPath, mw.session)
+	login.Show()
+	mw.window.Close()
+	mw.db.Close()
+}
+
+// trackSecret registers d to be hidden when the vault is locked. It must
+// be untracked once it closes.
+func (mw *MainWindow) trackSecret(d dialog.Dialog) {
+	mw.secrets[d] = true
+}
+
+func (mw *MainWindow) untrackSecret(d dialog.Dialog) {
+	delete(mw.secrets, d)
+}
+
+// autoLockSettings returns the controls for the lock timeout and
+// lock-on-minimize settings.
+func autoLockSettings(mw *MainWindow) fyne.CanvasObject {
+	timeout, err := mw.db.AutoLockTimeout()
+	if err != nil {
+		timeout = db.DefaultAutoLock
+	}
+	var options []string
+	for _, minutes := range autoLockOptions {
+		options = append(options, autoLockLabel(minutes))
+	}
+	timeoutSelect := widget.NewSelect(options, nil)
+	timeoutSelect.SetSelected(autoLockLabel(int(timeout / time.Minute)))
+	timeoutSelect.OnChanged = func(selected string) {
+		for _, minutes := range autoLockOptions {
+			if autoLockLabel(minutes) == selected {
+				timeout := time.Duration(minutes) * time.Minute
+				if err := mw.db.SetAutoLockTimeout(timeout); err != nil {
+					dialog.ShowError(err, mw.window)
+					return
+				}
+				mw.idle.SetTimeout(timeout)
+				return
+			}
+		}
+	}
+
+	onMinimize, _ := mw.db.LockOnMinimize()
+	minimizeCheck := widget.NewCheck("Lock when minimized", nil)
+	minimizeCheck.SetChecked(onMinimize)
+	minimizeCheck.OnChanged = func(enabled bool) {
+		if err := mw.db.SetLockOnMinimize(enabled); err != nil {
+			dialog.ShowError(err, mw.window)
+		}
+	}
+
+	return container.NewHBox(widget.NewLabel("Lock when idle:"), timeoutSelect, minimizeCheck)
+}
@@ -0,0 +1,356 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"spms/crypto"
+	"spms/db"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const noCategory = "None"
+
+// loadCategories returns every category with its name decrypted.
+func loadCategories(database *db.DB, session *crypto.Session) ([]db.Category, error) {
+	var categories []db.Category
+	err := session.WithKey(func(key []byte) error {
+		var err error
+		categories, err = database.GetCategories(key)
+		return err
+	})
+	return categories, err
+}
+
+var categoryIcons = map[string]fyne.Resource{
+	"folder":   theme.FolderIcon(),
+	"home":     theme.HomeIcon(),
+	"account":  theme.AccountIcon(),
+	"mail":     theme.MailComposeIcon(),
+	"computer": theme.ComputerIcon(),
+	"storage":  theme.StorageIcon(),
+	"settings": theme.SettingsIcon(),
+	"info":     theme.InfoIcon(),
+}
+
+// categoryColors is the palette offered in the category editor.
+var categoryColors = []struct{ name, hex string }{
+	{"Default", ""},
+	{"Red", "#e53935"},
+	{"Orange", "#fb8c00"},
+	{"Yellow", "#fdd835"},
+	{"Green", "#43a047"},
+	{"Teal", "#00897b"},
+	{"Blue", "#1e88e5"},
+	{"Purple", "#8e24aa"},
+	{"Grey", "#757575"},
+}
+
+func categoryIcon(name string) fyne.Resource {
+	if icon, ok := categoryIcons[name]; ok {
+		return icon
+	}
+	return theme.FolderIcon()
+}
+
+// categoryColor parses a "#rrggbb" color, falling back to the theme's
+// foreground color.
+func categoryColor(hex string) color.Color {
+	if v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32); err == nil && len(hex) == 7 {
+		return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+	}
+	return theme.Color(theme.ColorNameForeground)
+}
+
+// sortedCategories returns categories ordered by their full path.
+func sortedCategories(categories []db.Category) ([]db.Category, map[int]string) {
+	paths := db.CategoryPaths(categories)
+	sorted := append([]db.Category(nil), categories...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(paths[sorted[i].ID]) < strings.ToLower(paths[sorted[j].ID])
+	})
+	return sorted, paths
+}
+
+// newCategorySelect returns a select listing categories by path, with
+// selected preselected, and a function returning the chosen category ID.
+func newCategorySelect(categories []db.Category, selected *int) (*widget.Select, func() *int) {
+	sorted, paths := sortedCategories(categories)
+	options := []string{noCategory}
+	ids := make(map[string]int, len(sorted))
+	for _, c := range sorted {
+		options = append(options, paths[c.ID])
+		ids[paths[c.ID]] = c.ID
+	}
+
+	sel := widget.NewSelect(options, nil)
+	sel.SetSelected(noCategory)
+	if selected != nil {
+		if path, ok := paths[*selected]; ok {
+			sel.SetSelected(path)
+		}
+	}
+
+	return sel, func() *int {
+		id, ok := ids[sel.Selected]
+		if !ok {
+			return nil
+		}
+		return &id
+	}
+}
+
+// listRow is a row of the grouped password list: a category header or an
+// entry.
+type listRow struct {
+	header   bool
+	title    string
+	category *db.Category
+	entry    db.PasswordEntry
+}
+
+// listRows arranges entries for the password list. Entries sorted by
+// website are grouped by category, favorites get a group of their own when
+// they come first, and usage orders are shown as a flat list.
+func listRows(entries []db.PasswordEntry, categories []db.Category, order db.SortOrder) []listRow {
+	switch order {
+	case db.SortRecentlyUsed, db.SortFrequentlyUsed:
+		rows := make([]listRow, len(entries))
+		for i, entry := range entries {
+			rows[i] = listRow{entry: entry}
+		}
+		return rows
+	case db.SortFavoritesFirst:
+		var rows []listRow
+		var others []db.PasswordEntry
+		for _, entry := range entries {
+			if !entry.Favorite {
+				others = append(others, entry)
+				continue
+			}
+			if len(rows) == 0 {
+				rows = append(rows, listRow{header: true, title: "Favorites"})
+			}
+			rows = append(rows, listRow{entry: entry})
+		}
+		rest := groupEntries(others, categories)
+		if len(rows) > 0 && len(rest) > 0 && !rest[0].header {
+			rows = append(rows, listRow{header: true, title: "Uncategorized"})
+		}
+		return append(rows, rest...)
+	}
+	return groupEntries(entries, categories)
+}
+
+// groupEntries groups entries under headers for their category, ordered by
+// category path, with uncategorised entries last.
+func groupEntries(entries []db.PasswordEntry, categories []db.Category) []listRow {
+	byCategory := make(map[int][]db.PasswordEntry)
+	var uncategorised []db.PasswordEntry
+	for _, entry := range entries {
+		if entry.CategoryID == nil {
+			uncategorised = append(uncategorised, entry)
+			continue
+		}
+		byCategory[*entry.CategoryID] = append(byCategory[*entry.CategoryID], entry)
+	}
+
+	var rows []listRow
+	sorted, paths := sortedCategories(categories)
+	for i := range sorted {
+		group := byCategory[sorted[i].ID]
+		if len(group) == 0 {
+			continue
+		}
+		rows = append(rows, listRow{header: true, title: paths[sorted[i].ID], category: &sorted[i]})
+		delete(byCategory, sorted[i].ID)
+		for _, entry := range group {
+			rows = append(rows, listRow{entry: entry})
+		}
+	}
+	// Entries whose category disappeared are shown as uncategorised.
+	for _, group := range byCategory {
+		uncategorised = append(uncategorised, group...)
+	}
+
+	if len(uncategorised) > 0 {
+		if len(rows) > 0 {
+			rows = append(rows, listRow{header: true, title: "Uncategorized"})
+		}
+		for _, entry := range uncategorised {
+			rows = append(rows, listRow{entry: entry})
+		}
+	}
+	return rows
+}
+
+// showCategoryManager lists the categories with actions to create, edit and
+// delete them.
+func showCategoryManager(mw *MainWindow) {
+	var list *widget.List
+	var sorted []db.Category
+	var paths map[int]string
+	reload := func() {
+		sorted, paths = sortedCategories(mw.categories)
+		list.Refresh()
+	}
+
+	list = widget.NewList(
+		func() int {
+			return len(sorted)
+		},
+		func() fyne.CanvasObject {
+			swatch := canvas.NewRectangle(theme.Color(theme.ColorNameForeground))
+			swatch.SetMinSize(fyne.NewSize(12, 12))
+			return container.NewBorder(nil, nil,
+				container.NewHBox(container.NewCenter(swatch), widget.NewIcon(theme.FolderIcon())),
+				container.NewHBox(
+					widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil),
+					widget.NewButtonWithIcon("", theme.DeleteIcon(), nil),
+				),
+				widget.NewLabel("Category"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			c := sorted[id]
+			cont := obj.(*fyne.Container)
+			left := cont.Objects[1].(*fyne.Container)
+			buttons := cont.Objects[2].(*fyne.Container)
+
+			swatch := left.Objects[0].(*fyne.Container).Objects[0].(*canvas.Rectangle)
+			swatch.FillColor = categoryColor(c.Color)
+			swatch.Refresh()
+			left.Objects[1].(*widget.Icon).SetResource(categoryIcon(c.Icon))
+			cont.Objects[0].(*widget.Label).SetText(paths[c.ID])
+
+			buttons.Objects[0].(*widget.Button).OnTapped = func() {
+				showCategoryForm(mw, &c, reload)
+			}
+			buttons.Objects[1].(*widget.Button).OnTapped = func() {
+				showDeleteCategoryDialog(mw, c, reload)
+			}
+		},
+	)
+	reload()
+
+	addBtn := widget.NewButtonWithIcon("New Category", theme.ContentAddIcon(), func() {
+		showCategoryForm(mw, nil, reload)
+	})
+
+	d := dialog.NewCustom("Categories", "Close", container.NewBorder(addBtn, nil, nil, nil, list), mw.window)
+	d.Resize(fyne.NewSize(450, 400))
+	d.Show()
+}
+
+// showCategoryForm edits c, or creates a new category when c is nil.
+func showCategoryForm(mw *MainWindow, c *db.Category, onSaved func()) {
+	title := "New Category"
+	category := db.Category{}
+	if c != nil {
+		title = "Edit Category"
+		category = *c
+	}
+
+	name := widget.NewEntry()
+	name.SetText(category.Name)
+	mw.watchInput(name)
+
+	// A category cannot be its own parent; the db rejects deeper cycles.
+	var parents []db.Category
+	for _, other := range mw.categories {
+		if other.ID != category.ID {
+			parents = append(parents, other)
+		}
+	}
+	parentSelect, selectedParent := newCategorySelect(parents, category.ParentID)
+
+	var colorNames []string
+	for _, c := range categoryColors {
+		colorNames = append(colorNames, c.name)
+	}
+	colorSelect := widget.NewSelect(colorNames, nil)
+	colorSelect.SetSelected(categoryColors[0].name)
+	for _, c := range categoryColors {
+		if strings.EqualFold(c.hex, category.Color) {
+			colorSelect.SetSelected(c.name)
+		}
+	}
+
+	iconSelect := widget.NewSelect(db.CategoryIcons, nil)
+	iconSelect.SetSelected(db.CategoryIcons[0])
+	if category.Icon != "" {
+		iconSelect.SetSelected(category.Icon)
+	}
+
+	dialog.ShowForm(title, "Save", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Name", name),
+			widget.NewFormItem("Parent", parentSelect),
+			widget.NewFormItem("Color", colorSelect),
+			widget.NewFormItem("Icon", iconSelect),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			category.Name = name.Text
+			category.ParentID = selectedParent()
+			category.Icon = iconSelect.Selected
+			category.Color = ""
+			for _, c := range categoryColors {
+				if c.name == colorSelect.Selected {
+					category.Color = c.hex
+				}
+			}
+
+			err := mw.session.WithKey(func(key []byte) error {
+				if category.ID == 0 {
+					_, err := mw.db.CreateCategory(key, category)
+					return err
+				}
+				return mw.db.UpdateCategory(key, category)
+			})
+			if err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			mw.refresh()
+			onSaved()
+		}, mw.window)
+}
+
+func showDeleteCategoryDialog(mw *MainWindow, c db.Category, onDeleted func()) {
+	var others []db.Category
+	for _, other := range mw.categories {
+		if other.ID != c.ID {
+			others = append(others, other)
+		}
+	}
+	reassignSelect, reassignTo := newCategorySelect(others, nil)
+
+	dialog.ShowForm("Delete Category", "Delete", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("", widget.NewLabel(fmt.Sprintf("Delete %q? Subcategories move up one level.", c.Name))),
+			widget.NewFormItem("Move entries to", reassignSelect),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := mw.db.DeleteCategory(c.ID, reassignTo()); err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			mw.refresh()
+			onDeleted()
+		}, mw.window)
+}
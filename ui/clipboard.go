@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"spms/db"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// clipboardOptions are the choices offered for clearing copied secrets, in
+// seconds. Zero keeps them until the vault is locked.
+var clipboardOptions = []int{0, 10, 30, 60, 120}
+
+func clipboardLabel(seconds int) string {
+	if seconds == 0 {
+		return "On lock"
+	}
+	return fmt.Sprintf("After %d seconds", seconds)
+}
+
+// clipboardTimeout returns the configured clipboard timeout, falling back
+// to the default if it cannot be read.
+func clipboardTimeout(database *db.DB) time.Duration {
+	timeout, err := database.ClipboardClearTimeout()
+	if err != nil {
+		return db.DefaultClipboardClear
+	}
+	return timeout
+}
+
+// clipboardManager copies secrets to the clipboard and clears them again
+// after a timeout, unless something else has been copied since. It must
+// only be used from the UI goroutine.
+type clipboardManager struct {
+	clipboard fyne.Clipboard
+	timeout   time.Duration
+	value     string
+	deadline  time.Time
+	done      chan struct{}
+	listeners []func(remaining time.Duration)
+}
+
+func newClipboardManager(clipboard fyne.Clipboard, timeout time.Duration) *clipboardManager {
+	return &clipboardManager{clipboard: clipboard, timeout: timeout}
+}
+
+// Copy puts value on the clipboard and starts the countdown to clear it.
+func (c *clipboardManager) Copy(value string) {
+	c.stop()
+	c.clipboard.SetContent(value)
+	c.value = value
+	if c.timeout <= 0 {
+		c.notify(0)
+		return
+	}
+
+	c.deadline = time.Now().Add(c.timeout)
+	c.notify(c.timeout)
+
+	ticker := time.NewTicker(time.Second)
+	done := make(chan struct{})
+	c.done = done
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fyne.Do(func() {
+					// A newer copy may have replaced this countdown.
+					if c.done == done {
+						c.tick()
+					}
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (c *clipboardManager) tick() {
+	remaining := time.Until(c.deadline)
+	if remaining <= 0 {
+		c.Clear()
+		return
+	}
+	c.notify(remaining)
+}
+
+// Clear empties the clipboard if it still holds the copied value and
+// stops the countdown.
+func (c *clipboardManager) Clear() {
+	c.stop()
+	if c.value == "" {
+		return
+	}
+	if c.clipboard.Content() == c.value {
+		c.clipboard.SetContent("")
+	}
+	c.value = ""
+	c.notify(0)
+}
+
+// SetTimeout changes the timeout for later copies.
+func (c *clipboardManager) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// OnCountdown registers fn to be called with the time left before the
+// clipboard is cleared, and with zero once there is no countdown.
+func (c *clipboardManager) OnCountdown(fn func(remaining time.Duration)) {
+	c.listeners = append(c.listeners, fn)
+}
+
+func (c *clipboardManager) stop() {
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+}
+
+func (c *clipboardManager) notify(remaining time.Duration) {
+	for _, fn := range c.listeners {
+		fn(remaining)
+	}
+}
+
+// clipboardStatus shows the countdown of the clipboard manager.
+func clipboardStatus(c *clipboardManager) fyne.CanvasObject {
+	label := widget.NewLabel("")
+	label.Hide()
+	c.OnCountdown(func(remaining time.Duration) {
+		if remaining <= 0 {
+			label.Hide()
+			return
+		}
+		label.SetText(fmt.Sprintf("Clipboard will be cleared in %ds", int(remaining.Round(time.Second)/time.Second)))
+		label.Show()
+	})
+	return label
+}
+
+// clipboardSettings returns the control for the clipboard timeout.
+func clipboardSettings(mw *MainWindow) fyne.CanvasObject {
+	timeout := clipboardTimeout(mw.db)
+	var options []string
+	for _, seconds := range clipboardOptions {
+		options = append(options, clipboardLabel(seconds))
+	}
+	timeoutSelect := widget.NewSelect(options, nil)
+	timeoutSelect.SetSelected(clipboardLabel(int(timeout / time.Second)))
+	timeoutSelect.OnChanged = func(selected string) {
+		for _, seconds := range clipboardOptions {
+			if clipboardLabel(seconds) == selected {
+				timeout := time.Duration(seconds) * time.Second
+				if err := mw.db.SetClipboardClearTimeout(timeout); err != nil {
+					dialog.ShowError(err, mw.window)
+					return
+				}
+				mw.clipboard.SetTimeout(timeout)
+				return
+			}
+		}
+	}
+	return container.NewHBox(widget.NewLabel("Clear clipboard:"), timeoutSelect)
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"spms/crypto"
+	"spms/export"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	exportEncrypted = "Encrypt with an export passphrase"
+	exportPlaintext = "Plaintext"
+)
+
+// showExportDialog asks how to protect the export and where to save it.
+// Plaintext exports require the master password again.
+func showExportDialog(mw *MainWindow) {
+	formatSelect := widget.NewSelect([]string{"JSON", "CSV"}, nil)
+	formatSelect.SetSelected("JSON")
+
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("Export passphrase")
+	confirmPassphrase := widget.NewPasswordEntry()
+	confirmPassphrase.SetPlaceHolder("Confirm export passphrase")
+	encryptedBox := container.NewVBox(passphrase, confirmPassphrase)
+
+	masterPassword := widget.NewPasswordEntry()
+	masterPassword.SetPlaceHolder("Master password")
+	warning := widget.NewLabel("Anyone who can read the file will see every password.")
+	warning.Wrapping = fyne.TextWrapWord
+	plaintextBox := container.NewVBox(warning, masterPassword)
+	plaintextBox.Hide()
+	mw.watchInput(passphrase, confirmPassphrase, masterPassword)
+
+	protection := widget.NewRadioGroup([]string{exportEncrypted, exportPlaintext}, func(selected string) {
+		if selected == exportPlaintext {
+			encryptedBox.Hide()
+			plaintextBox.Show()
+		} else {
+			plaintextBox.Hide()
+			encryptedBox.Show()
+		}
+	})
+	protection.Required = true
+	protection.SetSelected(exportEncrypted)
+
+	content := container.NewVBox(
+		widget.NewLabel("Format:"),
+		formatSelect,
+		protection,
+		encryptedBox,
+		plaintextBox,
+	)
+
+	var d dialog.Dialog
+	d = dialog.NewCustomConfirm("Export Vault", "Export", "Cancel", content, func(confirmed bool) {
+		mw.untrackSecret(d)
+		if !confirmed {
+			return
+		}
+
+		format := export.ContentJSON
+		if formatSelect.Selected == "CSV" {
+			format = export.ContentCSV
+		}
+
+		encrypt := protection.Selected == exportEncrypted
+		if encrypt {
+			if passphrase.Text != confirmPassphrase.Text {
+				dialog.ShowError(fmt.Errorf("passphrases don't match"), mw.window)
+				return
+			}
+			if len(passphrase.Text) < export.MinPassphraseLength {
+				dialog.ShowError(fmt.Errorf("export passphrase must be at least %d characters", export.MinPassphraseLength), mw.window)
+				return
+			}
+		} else {
+			if err := mw.db.VerifyMasterPassword(masterPassword.Text); err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+		}
+
+		saveExport(mw, format, encrypt, passphrase.Text)
+	}, mw.window)
+	d.Resize(fyne.NewSize(420, 0))
+	mw.trackSecret(d)
+	d.Show()
+}
+
+// saveExport asks for the destination file and writes the export to it.
+func saveExport(mw *MainWindow, format export.Content, encrypt bool, passphrase string) {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		// The file has just been created; restrict it before writing.
+		if writer.URI().Scheme() == "file" {
+			if err := os.Chmod(writer.URI().Path(), 0600); err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+		}
+
+		var count int
+		err = mw.session.WithKey(func(key []byte) error {
+			v, err := export.Collect(mw.db, key)
+			if err != nil {
+				return err
+			}
+			count = len(v.Entries)
+
+			plain, err := export.Marshal(v, format)
+			defer crypto.ClearBytes(plain)
+			if err != nil {
+				return err
+			}
+			data := plain
+			if encrypt {
+				if data, err = export.Encrypt(plain, format, passphrase); err != nil {
+					return err
+				}
+			}
+			_, err = writer.Write(data)
+			return err
+		})
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("export failed: %w", err), mw.window)
+			return
+		}
+		dialog.ShowInformation("Export Complete", fmt.Sprintf("Exported %d entries.", count), mw.window)
+	}, mw.window)
+
+	name := "spms-export." + string(format)
+	if encrypt {
+		name = "spms-export-encrypted.json"
+	}
+	save.SetFileName(name)
+	save.Show()
+}
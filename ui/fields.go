@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"spms/crypto"
+	"spms/db"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type fieldRow struct {
+	typeSelect *widget.Select
+	name       *widget.Entry
+	value      *widget.Entry
+	box        *fyne.Container
+}
+
+// fieldEditor edits the custom fields of an entry inside a form. onInput
+// is called whenever a field is edited.
+type fieldEditor struct {
+	rows    []*fieldRow
+	list    *fyne.Container
+	onInput func()
+}
+
+func newFieldEditor(fields []db.CustomField, onInput func()) *fieldEditor {
+	fe := &fieldEditor{list: container.NewVBox(), onInput: onInput}
+	for _, f := range fields {
+		fe.addRow(f.Type, f.Name, f.Value)
+	}
+	return fe
+}
+
+func (fe *fieldEditor) addRow(fieldType db.FieldType, name, value string) {
+	row := &fieldRow{}
+
+	row.name = widget.NewEntry()
+	row.name.SetPlaceHolder("Name")
+	row.name.SetText(name)
+
+	row.value = widget.NewEntry()
+	row.value.SetPlaceHolder("Value")
+	row.value.Password = fieldType.IsSecret()
+	row.value.SetText(value)
+	notifyOnInput(fe.onInput, row.name, row.value)
+
+	var options []string
+	for _, t := range db.FieldTypes {
+		options = append(options, string(t))
+	}
+	row.typeSelect = widget.NewSelect(options, func(selected string) {
+		row.value.Password = db.FieldType(selected).IsSecret()
+		row.value.Refresh()
+	})
+	row.typeSelect.SetSelected(string(fieldType))
+
+	removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		fe.removeRow(row)
+	})
+
+	row.box = container.NewBorder(nil, nil, row.typeSelect, removeBtn,
+		container.NewGridWithColumns(2, row.name, row.value))
+	fe.rows = append(fe.rows, row)
+	fe.list.Add(row.box)
+}
+
+func (fe *fieldEditor) removeRow(row *fieldRow) {
+	for i, r := range fe.rows {
+		if r == row {
+			fe.rows = append(fe.rows[:i], fe.rows[i+1:]...)
+			break
+		}
+	}
+	fe.list.Remove(row.box)
+}
+
+func (fe *fieldEditor) content() fyne.CanvasObject {
+	addBtn := widget.NewButtonWithIcon("Add Field", theme.ContentAddIcon(), func() {
+		fe.addRow(db.FieldText, "", "")
+	})
+	return container.NewVBox(fe.list, addBtn)
+}
+
+// fields returns the edited fields, skipping blank rows.
+func (fe *fieldEditor) fields() ([]db.CustomField, error) {
+	var fields []db.CustomField
+	for _, row := range fe.rows {
+		if row.name.Text == "" && row.value.Text == "" {
+			continue
+		}
+		fieldType := db.FieldType(row.typeSelect.Selected)
+		if row.name.Text == "" {
+			return nil, fmt.Errorf("custom field name cannot be empty")
+		}
+		if err := fieldType.Validate(row.value.Text); err != nil {
+			return nil, fmt.Errorf("%s: %w", row.name.Text, err)
+		}
+		fields = append(fields, db.CustomField{
+			Type:  fieldType,
+			Name:  row.name.Text,
+			Value: row.value.Text,
+		})
+	}
+	return fields, nil
+}
+
+func loadFields(database *db.DB, session *crypto.Session, entryID int) ([]db.CustomField, error) {
+	var fields []db.CustomField
+	err := session.WithKey(func(key []byte) error {
+		var err error
+		fields, err = database.GetFields(key, entryID)
+		return err
+	})
+	return fields, err
+}
+
+// fieldDetails renders custom fields read-only, masking secret values.
+func fieldDetails(clipboard *clipboardManager, fields []db.CustomField) fyne.CanvasObject {
+	box := container.NewVBox()
+	for _, f := range fields {
+		value := f.Value
+
+		var valueWidget fyne.CanvasObject
+		switch {
+		case f.Type.IsSecret():
+			entry := widget.NewPasswordEntry()
+			entry.SetText(value)
+			valueWidget = entry
+		case f.Type == db.FieldURL:
+			if u, err := url.Parse(value); err == nil {
+				valueWidget = widget.NewHyperlink(value, u)
+			} else {
+				valueWidget = widget.NewLabel(value)
+			}
+		default:
+			valueWidget = widget.NewLabel(value)
+		}
+
+		copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			clipboard.Copy(value)
+		})
+
+		box.Add(widget.NewLabel(f.Name + ":"))
+		box.Add(container.NewBorder(nil, nil, nil, copyBtn, valueWidget))
+	}
+	return box
+}
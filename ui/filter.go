@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// chip is a filter option shown by filterChips.
+type chip struct {
+	id    int
+	label string
+	icon  fyne.Resource
+}
+
+// filterChips shows a row of toggle buttons for filtering the password
+// list by category or tag.
+type filterChips struct {
+	title     string
+	box       *fyne.Container
+	selected  []int
+	onChanged func(selected []int)
+}
+
+// newFilterChips returns chips that call onChanged with the IDs of the
+// selected options, which is empty when none are.
+func newFilterChips(title string, onChanged func(selected []int)) *filterChips {
+	return &filterChips{title: title, box: container.NewHBox(), onChanged: onChanged}
+}
+
+func (c *filterChips) content() fyne.CanvasObject {
+	return container.NewHScroll(c.box)
+}
+
+// update rebuilds the chips for options, dropping selections of options
+// that no longer exist.
+func (c *filterChips) update(options []chip) {
+	var selected []int
+	for _, id := range c.selected {
+		if slices.ContainsFunc(options, func(o chip) bool { return o.id == id }) {
+			selected = append(selected, id)
+		}
+	}
+	changed := len(selected) != len(c.selected)
+	c.selected = selected
+
+	c.box.RemoveAll()
+	if len(options) > 0 {
+		c.box.Add(widget.NewLabel(c.title))
+	}
+	for _, option := range options {
+		id := option.id
+		var btn *widget.Button
+		btn = widget.NewButtonWithIcon(option.label, option.icon, func() {
+			if i := slices.Index(c.selected, id); i >= 0 {
+				c.selected = slices.Delete(c.selected, i, i+1)
+				btn.Importance = widget.MediumImportance
+			} else {
+				c.selected = append(c.selected, id)
+				btn.Importance = widget.HighImportance
+			}
+			btn.Refresh()
+			c.onChanged(slices.Clone(c.selected))
+		})
+		if slices.Contains(c.selected, id) {
+			btn.Importance = widget.HighImportance
+		}
+		c.box.Add(btn)
+	}
+
+	if changed {
+		c.onChanged(slices.Clone(c.selected))
+	}
+}
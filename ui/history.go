@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"spms/crypto"
+	"spms/db"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+func decryptHistory(database *db.DB, session *crypto.Session, h db.HistoryEntry) (string, error) {
+	var password string
+	err := session.WithKey(func(key []byte) error {
+		decrypted, err := database.DecryptHistory(key, h)
+		if err != nil {
+			return err
+		}
+		defer crypto.ClearBytes(decrypted)
+		password = string(decrypted)
+		return nil
+	})
+	return password, err
+}
+
+// historyDetails lists the previous passwords of an entry. Passwords are
+// only decrypted when revealed or copied. onRestored is called after a
+// previous password has been made current.
+func historyDetails(mw *MainWindow, history []db.HistoryEntry, onRestored func()) fyne.CanvasObject {
+	box := container.NewVBox()
+	for _, h := range history {
+		value := widget.NewPasswordEntry()
+		value.SetPlaceHolder("••••••••")
+		value.Disable()
+
+		var revealBtn *widget.Button
+		revealBtn = widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
+			if value.Text != "" {
+				value.SetText("")
+				value.Password = true
+				revealBtn.SetIcon(theme.VisibilityIcon())
+				return
+			}
+			password, err := decryptHistory(mw.db, mw.session, h)
+			if err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			value.Password = false
+			value.SetText(password)
+			revealBtn.SetIcon(theme.VisibilityOffIcon())
+		})
+
+		copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			password, err := decryptHistory(mw.db, mw.session, h)
+			if err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			mw.clipboard.Copy(password)
+		})
+
+		restoreBtn := widget.NewButtonWithIcon("", theme.HistoryIcon(), func() {
+			dialog.ShowConfirm("Restore Password", "Make this password current again?", func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				err := mw.session.WithKey(func(key []byte) error {
+					return mw.db.RestorePassword(key, h.ID)
+				})
+				if err != nil {
+					dialog.ShowError(err, mw.window)
+					return
+				}
+				onRestored()
+			}, mw.window)
+		})
+
+		box.Add(widget.NewLabel(h.CreatedAt.Local().Format("2006-01-02 15:04")))
+		box.Add(container.NewBorder(nil, nil, nil,
+			container.NewHBox(revealBtn, copyBtn, restoreBtn), value))
+	}
+	return box
+}
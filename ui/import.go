@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"spms/importer"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// importExtensions are the file extensions offered for each format.
+var importExtensions = map[importer.Format][]string{
+	importer.Bitwarden:   {".json", ".csv"},
+	importer.KeePass:     {".xml"},
+	importer.OnePassword: {".csv"},
+	importer.LastPass:    {".csv"},
+	importer.Chrome:      {".csv"},
+	importer.SPMS:        {".json", ".csv"},
+}
+
+// showImportDialog asks which password manager the export comes from and
+// then for the file to import.
+func showImportDialog(mw *MainWindow) {
+	labels := make([]string, len(importer.Formats))
+	for i, f := range importer.Formats {
+		labels[i] = f.String()
+	}
+	formatSelect := widget.NewSelect(labels, nil)
+	formatSelect.SetSelectedIndex(0)
+
+	content := container.NewVBox(widget.NewLabel("Import from:"), formatSelect)
+	d := dialog.NewCustomConfirm("Import", "Choose File", "Cancel", content, func(confirmed bool) {
+		if !confirmed || formatSelect.SelectedIndex() < 0 {
+			return
+		}
+		openImport(mw, importer.Formats[formatSelect.SelectedIndex()])
+	}, mw.window)
+	d.Resize(fyne.NewSize(360, 0))
+	d.Show()
+}
+
+// openImport reads the chosen export and shows its preview.
+func openImport(mw *MainWindow, format importer.Format) {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+
+		res, err := importer.Parse(format, bytes.NewReader(data))
+		if errors.Is(err, importer.ErrPassphraseRequired) {
+			askImportPassphrase(mw, format, data)
+			return
+		}
+		if err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+		previewImport(mw, res)
+	}, mw.window)
+	open.SetFilter(storage.NewExtensionFileFilter(importExtensions[format]))
+	open.Show()
+}
+
+// askImportPassphrase asks for the passphrase of an encrypted export and
+// then shows its preview.
+func askImportPassphrase(mw *MainWindow, format importer.Format, data []byte) {
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("Export passphrase")
+	mw.watchInput(passphrase)
+
+	var d dialog.Dialog
+	d = dialog.NewForm("Encrypted Export", "Open", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Passphrase", passphrase)},
+		func(confirmed bool) {
+			mw.untrackSecret(d)
+			if !confirmed {
+				return
+			}
+			res, err := importer.ParseWithPassphrase(format, bytes.NewReader(data), passphrase.Text)
+			if err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			previewImport(mw, res)
+		}, mw.window)
+	mw.trackSecret(d)
+	d.Show()
+}
+
+// previewImport marks the records that are already in the vault and shows
+// the preview.
+func previewImport(mw *MainWindow, res *importer.Result) {
+	var duplicates []bool
+	err := mw.session.WithKey(func(key []byte) error {
+		var err error
+		duplicates, err = importer.FindDuplicates(mw.db, key, res.Records)
+		return err
+	})
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	showImportPreview(mw, res, duplicates)
+}
+
+// showImportPreview lists the records found in an export so the user can
+// pick which to import. Duplicates of existing entries start unchecked.
+func showImportPreview(mw *MainWindow, res *importer.Result, duplicates []bool) {
+	selected := make([]bool, len(res.Records))
+	var duplicateCount int
+	for i, dup := range duplicates {
+		selected[i] = !dup
+		if dup {
+			duplicateCount++
+		}
+	}
+
+	list := widget.NewList(
+		func() int { return len(res.Records) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil), nil, widget.NewLabel("Entry"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			r := res.Records[id]
+			cont := obj.(*fyne.Container)
+			label := cont.Objects[0].(*widget.Label)
+			check := cont.Objects[1].(*widget.Check)
+
+			text := fmt.Sprintf("%s (%s)", r.Website(), r.Username)
+			if path := r.CategoryPath(); path != "" {
+				text += " in " + path
+			}
+			if duplicates[id] {
+				text += " — already in vault"
+			}
+			label.SetText(text)
+
+			check.OnChanged = nil
+			check.SetChecked(selected[id])
+			check.OnChanged = func(checked bool) {
+				selected[id] = checked
+			}
+		},
+	)
+
+	summary := fmt.Sprintf("Found %d entries", len(res.Records))
+	if duplicateCount > 0 {
+		summary += fmt.Sprintf(", %d already in the vault", duplicateCount)
+	}
+	summary += "."
+	if len(res.Skipped) > 0 {
+		summary += fmt.Sprintf(" %d rows will be skipped.", len(res.Skipped))
+	}
+
+	var details []*widget.AccordionItem
+	if len(res.Skipped) > 0 {
+		details = append(details, widget.NewAccordionItem(
+			fmt.Sprintf("Skipped rows (%d)", len(res.Skipped)), problemList(res.Skipped)))
+	}
+	if len(res.Warnings) > 0 {
+		details = append(details, widget.NewAccordionItem(
+			fmt.Sprintf("Warnings (%d)", len(res.Warnings)), problemList(res.Warnings)))
+	}
+
+	selectAll := widget.NewButton("Select All", func() {
+		for i := range selected {
+			selected[i] = true
+		}
+		list.Refresh()
+	})
+	selectNone := widget.NewButton("Select None", func() {
+		for i := range selected {
+			selected[i] = false
+		}
+		list.Refresh()
+	})
+
+	content := container.NewBorder(
+		container.NewVBox(widget.NewLabel(summary), container.NewHBox(selectAll, selectNone)),
+		widget.NewAccordion(details...),
+		nil, nil,
+		list,
+	)
+
+	var d dialog.Dialog
+	d = dialog.NewCustomConfirm("Import Preview", "Import", "Cancel", content, func(confirmed bool) {
+		mw.untrackSecret(d)
+		if !confirmed {
+			return
+		}
+
+		var records []importer.Record
+		for i, r := range res.Records {
+			if selected[i] {
+				records = append(records, r)
+			}
+		}
+		importRecords(mw, records)
+	}, mw.window)
+	d.Resize(fyne.NewSize(560, 480))
+	mw.trackSecret(d)
+	d.Show()
+}
+
+// importRecords adds records to the vault and reports the outcome.
+func importRecords(mw *MainWindow, records []importer.Record) {
+	var added int
+	var failed []importer.Problem
+	err := mw.session.WithKey(func(key []byte) error {
+		var err error
+		added, failed, err = importer.Import(mw.db, key, records)
+		return err
+	})
+	mw.refresh()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("import stopped after %d entries: %w", added, err), mw.window)
+		return
+	}
+
+	message := fmt.Sprintf("Imported %d entries.", added)
+	if len(failed) == 0 {
+		dialog.ShowInformation("Import Complete", message, mw.window)
+		return
+	}
+	content := container.NewBorder(
+		widget.NewLabel(fmt.Sprintf("%s %d could not be added:", message, len(failed))),
+		nil, nil, nil,
+		problemList(failed),
+	)
+	d := dialog.NewCustom("Import Complete", "OK", content, mw.window)
+	d.Resize(fyne.NewSize(480, 320))
+	d.Show()
+}
+
+// problemList shows import problems one per line.
+func problemList(problems []importer.Problem) fyne.CanvasObject {
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = p.String()
+	}
+	label := widget.NewLabel(strings.Join(lines, "\n"))
+	label.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(label)
+	scroll.SetMinSize(fyne.NewSize(0, 120))
+	return scroll
+}
@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"crypto/rand"
 	"fmt"
 	"spms/crypto"
 	"spms/db"
@@ -15,13 +14,40 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-func CreateLoginWindow(app fyne.App, db *db.DB) fyne.Window {
+// CreateLoginWindow opens the vault at vaultPath and asks for its master
+// password, or for a new one if the vault is empty.
+func CreateLoginWindow(app fyne.App, vaultPath string, session *crypto.Session) fyne.Window {
 	window := app.NewWindow("SPMS - Login")
 	window.Resize(fyne.NewSize(500, 400))
 	window.SetFixedSize(true)
 
-	_, encryptedCheck, err := db.GetMasterKey()
-	isFirstTime := err != nil || len(encryptedCheck) == 0
+	picker := vaultPicker(app, window, vaultPath, session)
+
+	database, err := db.NewDB(vaultPath)
+	if err != nil {
+		window.SetContent(container.NewVBox(
+			picker,
+			widget.NewLabel(fmt.Sprintf("Failed to open %s:", vaultPath)),
+			widget.NewLabel(err.Error()),
+		))
+		return window
+	}
+	app.Lifecycle().SetOnStopped(func() {
+		database.Close()
+	})
+
+	window.SetOnClosed(func() {
+		if session.IsLocked() {
+			database.Close()
+		}
+	})
+	createLoginForm(app, vaultPath, window, picker, database, session)
+	return window
+}
+
+func createLoginForm(app fyne.App, vaultPath string, window fyne.Window, picker fyne.CanvasObject, db *db.DB, session *crypto.Session) {
+	masterKey, err := db.GetMasterKey()
+	isFirstTime := err != nil || masterKey == nil
 
 	title := widget.NewLabel("Secure Password Manager")
 	title.TextStyle = fyne.TextStyle{Bold: true, Italic: true}
@@ -55,13 +81,25 @@ func CreateLoginWindow(app fyne.App, db *db.DB) fyne.Window {
 		confirmEntry.Hide()
 	}
 
+	var cipherOptions []string
+	for _, alg := range crypto.Algorithms() {
+		cipherOptions = append(cipherOptions, alg.String())
+	}
+	cipherSelect := widget.NewSelect(cipherOptions, nil)
+	cipherSelect.SetSelected(crypto.DefaultAlgorithm.String())
+	if !isFirstTime {
+		cipherSelect.Hide()
+	}
+
 	form := container.NewVBox(
 		container.NewBorder(nil, nil, nil, nil, passwordEntry),
 		confirmEntry,
+		cipherSelect,
 		strengthLabel,
 	)
 
-	loginBtn := widget.NewButtonWithIcon("Login", theme.LoginIcon(), func() {
+	var loginBtn *widget.Button
+	loginBtn = widget.NewButtonWithIcon("Login", theme.LoginIcon(), func() {
 		if isFirstTime {
 			if passwordEntry.Text != confirmEntry.Text {
 				dialog.ShowError(fmt.Errorf("passwords don't match"), window)
@@ -72,59 +110,73 @@ func CreateLoginWindow(app fyne.App, db *db.DB) fyne.Window {
 				return
 			}
 
-			salt := make([]byte, crypto.DefaultParams.SaltLength)
-			if _, err := rand.Read(salt); err != nil {
-				dialog.ShowError(err, window)
-				return
+			cipher := crypto.DefaultAlgorithm
+			for _, alg := range crypto.Algorithms() {
+				if alg.String() == cipherSelect.Selected {
+					cipher = alg
+				}
 			}
 
-			key, err := crypto.DeriveKey(passwordEntry.Text, salt)
+			key, err := db.InitVault(passwordEntry.Text, cipher)
 			if err != nil {
 				dialog.ShowError(err, window)
 				return
 			}
 			defer crypto.ClearBytes(key)
 
-			encryptedCheck, err := crypto.GetEncryptedCheck(key)
-			if err != nil {
+			if err := session.Unlock(key); err != nil {
 				dialog.ShowError(err, window)
 				return
 			}
 
-			if err := db.SaveMasterKey(salt, encryptedCheck); err != nil {
-				dialog.ShowError(err, window)
-				return
-			}
-
-			mainWindow := CreateMainWindow(app, db, key)
+			mainWindow := CreateMainWindow(app, vaultPath, db, session)
 			window.Close()
 			mainWindow.window.Show()
 		} else {
-			salt, encryptedCheck, err := db.GetMasterKey()
-			if err != nil {
+			key, err := db.UnlockVault(passwordEntry.Text)
+			if vaultWiped(err) {
+				// The vault is empty now; offer to set it up again. This
+				// form and its login button are gone.
+				createLoginForm(app, vaultPath, window, picker, db, session)
 				dialog.ShowError(err, window)
 				return
 			}
-			key, err := crypto.DeriveKey(passwordEntry.Text, salt)
 			if err != nil {
 				dialog.ShowError(err, window)
+				delayLogin(loginBtn, db)
 				return
 			}
 			defer crypto.ClearBytes(key)
 
-			if !crypto.VerifyMasterKey(key, encryptedCheck) {
-				dialog.ShowError(fmt.Errorf("invalid master password"), window)
+			if err := session.Unlock(key); err != nil {
+				dialog.ShowError(err, window)
 				return
 			}
 
-			mainWindow := CreateMainWindow(app, db, key)
+			_, purgeErr := db.PurgeExpired()
+
+			mainWindow := CreateMainWindow(app, vaultPath, db, session)
 			window.Close()
 			mainWindow.window.Show()
+
+			if purgeErr != nil {
+				dialog.ShowError(fmt.Errorf("failed to purge trash: %w", purgeErr), mainWindow.window)
+			}
+			showFailedLogins(mainWindow.window, db)
+
+			mk, err := db.GetMasterKey()
+			if err == nil && mk.Params.WeakerThan(crypto.DefaultParams) {
+				offerKDFUpgrade(mainWindow, []byte(passwordEntry.Text))
+			}
 		}
 	})
 
+	if !isFirstTime {
+		delayLogin(loginBtn, db)
+	}
+
 	changePasswordBtn := widget.NewButtonWithIcon("Change Master Password", theme.SettingsIcon(), func() {
-		showChangePasswordDialog(window, db)
+		showChangePasswordDialog(window, db, session, nil, nil)
 	})
 	if isFirstTime {
 		changePasswordBtn.Hide()
@@ -132,6 +184,7 @@ func CreateLoginWindow(app fyne.App, db *db.DB) fyne.Window {
 
 	content := container.NewVBox(
 		title,
+		picker,
 		layout.NewSpacer(),
 		form,
 		layout.NewSpacer(),
@@ -141,10 +194,12 @@ func CreateLoginWindow(app fyne.App, db *db.DB) fyne.Window {
 	)
 
 	window.SetContent(content)
-	return window
 }
 
-func showChangePasswordDialog(parent fyne.Window, db *db.DB) {
+// showChangePasswordDialog changes the master password. onChanged, if not
+// nil, is called after a successful change and onInput as the passwords
+// are typed.
+func showChangePasswordDialog(parent fyne.Window, db *db.DB, session *crypto.Session, onChanged, onInput func()) {
 	currentPass := widget.NewPasswordEntry()
 	newPass := widget.NewPasswordEntry()
 	confirmPass := widget.NewPasswordEntry()
@@ -154,6 +209,9 @@ func showChangePasswordDialog(parent fyne.Window, db *db.DB) {
 		strength := utils.EvaluatePasswordStrength(text)
 		strengthLabel.SetText(fmt.Sprintf("Strength: %d%%", strength))
 	}
+	notifyOnInput(onInput, currentPass, newPass, confirmPass)
+
+	rotateCheck := widget.NewCheck("Re-encrypt all entries with a new vault key", nil)
 
 	dialog.ShowCustom("Change Master Password", "Cancel",
 		container.NewVBox(
@@ -164,25 +222,8 @@ func showChangePasswordDialog(parent fyne.Window, db *db.DB) {
 			widget.NewLabel("Confirm New Password:"),
 			confirmPass,
 			strengthLabel,
+			rotateCheck,
 			widget.NewButtonWithIcon("Change", theme.ConfirmIcon(), func() {
-				salt, encryptedCheck, err := db.GetMasterKey()
-				if err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-
-				oldKey, err := crypto.DeriveKey(currentPass.Text, salt)
-				if err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-				defer crypto.ClearBytes(oldKey)
-
-				if !crypto.VerifyMasterKey(oldKey, encryptedCheck) {
-					dialog.ShowError(fmt.Errorf("current password is incorrect"), parent)
-					return
-				}
-
 				if newPass.Text != confirmPass.Text {
 					dialog.ShowError(fmt.Errorf("new passwords don't match"), parent)
 					return
@@ -193,54 +234,57 @@ func showChangePasswordDialog(parent fyne.Window, db *db.DB) {
 					return
 				}
 
-				newSalt := make([]byte, crypto.DefaultParams.SaltLength)
-				if _, err := rand.Read(newSalt); err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-
-				newKey, err := crypto.DeriveKey(newPass.Text, newSalt)
-				if err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-				defer crypto.ClearBytes(newKey)
-
-				newEncryptedCheck, err := crypto.GetEncryptedCheck(newKey)
-				if err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-
-				entries, err := db.GetAllEntries()
-				if err != nil {
-					dialog.ShowError(err, parent)
-					return
-				}
-
-				for _, entry := range entries {
-					decrypted, err := crypto.Decrypt(entry.EncryptedPassword, oldKey)
+				if rotateCheck.Checked {
+					newKey, err := db.RotateVaultKey(currentPass.Text, newPass.Text)
 					if err != nil {
-						continue // Skip failed decryptions
+						dialog.ShowError(err, parent)
+						return
 					}
-					newEncrypted, err := crypto.Encrypt(decrypted, newKey)
-					if err != nil {
-						continue // Skip failed encryptions
-					}
-					err = db.UpdateEntry(entry.ID, entry.Website, entry.Username, newEncrypted, entry.Notes, entry.CategoryID)
-					if err != nil {
-						continue // Skip failed updates
-					}
-				}
+					defer crypto.ClearBytes(newKey)
 
-				if err := db.SaveMasterKey(newSalt, newEncryptedCheck); err != nil {
+					if !session.IsLocked() {
+						if err := session.Unlock(newKey); err != nil {
+							dialog.ShowError(err, parent)
+							return
+						}
+					}
+				} else if err := db.ChangeMasterPassword(currentPass.Text, newPass.Text); err != nil {
 					dialog.ShowError(err, parent)
 					return
 				}
 
+				if onChanged != nil {
+					onChanged()
+				}
 				dialog.ShowInformation("Success", "Master password changed", parent)
 			}),
 		),
 		parent,
 	)
 }
+
+// offerKDFUpgrade asks whether to re-wrap the vault key with the default
+// KDF parameters. password is cleared once the dialog is answered, or
+// hidden when the vault locks.
+func offerKDFUpgrade(mw *MainWindow, password []byte) {
+	var d dialog.Dialog
+	d = dialog.NewConfirm("Upgrade Key Derivation",
+		"This vault uses weaker key derivation settings than the current defaults.\nUpgrade it now?",
+		func(confirmed bool) {
+			mw.untrackSecret(d)
+			defer crypto.ClearBytes(password)
+			if !confirmed {
+				return
+			}
+			err := mw.session.WithKey(func(key []byte) error {
+				return mw.db.RewrapVaultKey(string(password), key)
+			})
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("upgrade failed: %w", err), mw.window)
+				return
+			}
+			dialog.ShowInformation("Success", "Key derivation settings upgraded", mw.window)
+		}, mw.window)
+	mw.trackSecret(d)
+	d.Show()
+}
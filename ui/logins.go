@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"spms/db"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// failedLoginLimits are the choices offered for acting on repeated failed
+// logins. Zero only delays further attempts.
+var failedLoginLimits = []int{0, 5, 10, 20}
+
+var failureActionLabels = map[db.FailureAction]string{
+	db.FailureLock: "Lock for an hour",
+	db.FailureWipe: "Erase the vault",
+}
+
+func failedLoginLabel(limit int) string {
+	if limit == 0 {
+		return "Never"
+	}
+	return fmt.Sprintf("After %d failed logins", limit)
+}
+
+// delayLogin disables btn until the vault accepts login attempts again.
+func delayLogin(btn *widget.Button, database *db.DB) {
+	until, err := database.LoginDelay()
+	if err != nil || until.IsZero() {
+		return
+	}
+	btn.Disable()
+	time.AfterFunc(time.Until(until), func() {
+		fyne.Do(btn.Enable)
+	})
+}
+
+// vaultWiped reports whether err is from the failed login that erased the
+// vault.
+func vaultWiped(err error) bool {
+	return errors.Is(err, db.ErrVaultWiped)
+}
+
+// showFailedLogins tells the user about failed attempts made since the
+// previous unlock.
+func showFailedLogins(parent fyne.Window, database *db.DB) {
+	info, err := database.LastLoginInfo()
+	if err != nil || info.FailedAttempts == 0 {
+		return
+	}
+	message := fmt.Sprintf("%d failed login attempts were made since the last unlock.\nThe latest was at %s.",
+		info.FailedAttempts, info.LastFailure.Local().Format("2006-01-02 15:04:05"))
+	if info.FailedAttempts == 1 {
+		message = fmt.Sprintf("A failed login attempt was made at %s since the last unlock.",
+			info.LastFailure.Local().Format("2006-01-02 15:04:05"))
+	}
+	dialog.ShowInformation("Failed Login Attempts", message, parent)
+}
+
+// failedLoginSettings returns the controls for what happens after repeated
+// failed logins.
+func failedLoginSettings(mw *MainWindow) fyne.CanvasObject {
+	limit, action, err := mw.db.FailedLoginLimit()
+	if err != nil {
+		limit, action = 0, db.FailureLock
+	}
+
+	var limitOptions []string
+	for _, n := range failedLoginLimits {
+		limitOptions = append(limitOptions, failedLoginLabel(n))
+	}
+	limitSelect := widget.NewSelect(limitOptions, nil)
+	limitSelect.SetSelected(failedLoginLabel(limit))
+
+	actionSelect := widget.NewSelect([]string{failureActionLabels[db.FailureLock], failureActionLabels[db.FailureWipe]}, nil)
+	actionSelect.SetSelected(failureActionLabels[action])
+	if limit == 0 {
+		actionSelect.Disable()
+	}
+
+	save := func(newLimit int, newAction db.FailureAction) {
+		if err := mw.db.SetFailedLoginLimit(newLimit, newAction); err != nil {
+			dialog.ShowError(err, mw.window)
+			limitSelect.SetSelected(failedLoginLabel(limit))
+			actionSelect.SetSelected(failureActionLabels[action])
+			return
+		}
+		limit, action = newLimit, newAction
+		if limit == 0 {
+			actionSelect.Disable()
+		} else {
+			actionSelect.Enable()
+		}
+	}
+
+	limitSelect.OnChanged = func(selected string) {
+		for _, n := range failedLoginLimits {
+			if failedLoginLabel(n) == selected && n != limit {
+				save(n, action)
+				return
+			}
+		}
+	}
+	actionSelect.OnChanged = func(selected string) {
+		if selected == failureActionLabels[action] {
+			return
+		}
+		if selected == failureActionLabels[db.FailureLock] {
+			save(limit, db.FailureLock)
+			return
+		}
+		dialog.ShowConfirm("Erase Vault",
+			fmt.Sprintf("Permanently erase every entry after %d failed logins in a row?", limit),
+			func(confirmed bool) {
+				if !confirmed {
+					actionSelect.SetSelected(failureActionLabels[action])
+					return
+				}
+				save(limit, db.FailureWipe)
+			}, mw.window)
+	}
+
+	return container.NewHBox(widget.NewLabel("Failed logins:"), limitSelect, actionSelect)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"spms/crypto"
 	"spms/db"
+	"spms/otp"
 	"spms/utils"
 
 	"fyne.io/fyne/v2"
@@ -14,36 +15,117 @@ import (
 )
 
 type MainWindow struct {
-	window fyne.Window
-	db     *db.DB
-	key    []byte
+	app        fyne.App
+	vaultPath  string
+	window     fyne.Window
+	db         *db.DB
+	session    *crypto.Session
+	idle       *idleTimer
+	clipboard  *clipboardManager
+	secrets    map[dialog.Dialog]bool // open dialogs showing secrets
+	foreground bool
+	locked     bool
+	filter     db.EntryFilter
+	categories []db.Category
+	tags       []db.Tag    // tags in use
+	entries    *entryStore // entries matching filter
+	trash      *entryStore
 }
 
-func CreateMainWindow(app fyne.App, db *db.DB, key []byte) *MainWindow {
+// CreateMainWindow shows the unlocked vault. Locking it closes the window
+// and reopens vaultPath in a login window.
+func CreateMainWindow(app fyne.App, vaultPath string, db *db.DB, session *crypto.Session) *MainWindow {
 	mw := &MainWindow{
-		window: app.NewWindow("SPMS - Password Vault"),
-		db:     db,
-		key:    key,
+		app:       app,
+		vaultPath: vaultPath,
+		window:    app.NewWindow("SPMS - Password Vault"),
+		db:        db,
+		session:   session,
+		secrets:   make(map[dialog.Dialog]bool),
 	}
 	mw.window.Resize(fyne.NewSize(800, 600))
 
+	if order, err := mw.db.SortOrder(); err == nil {
+		mw.filter.Sort = order
+	}
+	mw.clipboard = newClipboardManager(mw.window.Clipboard(), clipboardTimeout(mw.db))
+	mw.entries = newEntryStore(session, mw.searchEntries)
+	mw.trash = newEntryStore(session, mw.db.GetTrash)
+
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Passwords", createPasswordTab(mw)),
-		container.NewTabItem("Generator", createGeneratorTab(app)),
+		container.NewTabItem("Trash", createTrashTab(mw)),
+		container.NewTabItem("Generator", createGeneratorTab(mw)),
+		container.NewTabItem("Settings", createSettingsTab(mw)),
 	)
 
-	mw.window.SetContent(tabs)
+	content := container.NewBorder(nil, clipboardStatus(mw.clipboard), nil, nil, tabs)
+	mw.window.SetContent(newActivityMonitor(content, mw.touch))
+	mw.startAutoLock()
+	mw.refresh()
 	return mw
 }
 
+func (mw *MainWindow) searchEntries(key []byte) ([]db.PasswordEntry, error) {
+	return mw.db.SearchEntries(key, mw.filter)
+}
+
+// refresh reloads the categories and cached entries after the vault has
+// changed.
+func (mw *MainWindow) refresh() {
+	mw.touch()
+	categories, err := loadCategories(mw.db, mw.session)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	mw.categories = categories
+
+	tags, err := loadTags(mw.db, mw.session, 0)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	mw.tags = tags
+
+	for _, store := range []*entryStore{mw.entries, mw.trash} {
+		if err := store.Reload(); err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+	}
+}
+
+func (mw *MainWindow) categoryChips() []chip {
+	sorted, paths := sortedCategories(mw.categories)
+	options := make([]chip, len(sorted))
+	for i, c := range sorted {
+		options[i] = chip{id: c.ID, label: paths[c.ID], icon: categoryIcon(c.Icon)}
+	}
+	return options
+}
+
+func (mw *MainWindow) tagChips() []chip {
+	options := make([]chip, len(mw.tags))
+	for i, t := range mw.tags {
+		options[i] = chip{id: t.ID, label: t.Name, icon: theme.MenuIcon()}
+	}
+	return options
+}
+
+func (mw *MainWindow) applyFilter() {
+	mw.touch()
+	if err := mw.entries.Reload(); err != nil {
+		dialog.ShowError(err, mw.window)
+	}
+}
+
 func createPasswordTab(mw *MainWindow) fyne.CanvasObject {
+	var rows []listRow
+
 	list := widget.NewList(
 		func() int {
-			entries, err := mw.db.GetAllEntries()
-			if err != nil {
-				return 0
-			}
-			return len(entries)
+			return len(rows)
 		},
 		func() fyne.CanvasObject {
 			return container.NewHBox(
@@ -52,35 +134,108 @@ func createPasswordTab(mw *MainWindow) fyne.CanvasObject {
 			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			entries, err := mw.db.GetAllEntries()
-			if err != nil {
+			if id >= len(rows) {
 				return
 			}
+			row := rows[id]
 			cont := obj.(*fyne.Container)
-			cont.Objects[1].(*widget.Label).SetText(entries[id].Website)
+			icon := cont.Objects[0].(*widget.Icon)
+			label := cont.Objects[1].(*widget.Label)
+
+			if row.header {
+				if row.category != nil {
+					icon.SetResource(categoryIcon(row.category.Icon))
+				} else {
+					icon.SetResource(theme.FolderIcon())
+				}
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(row.title)
+				return
+			}
+			icon.SetResource(theme.DocumentIcon())
+			label.TextStyle = fyne.TextStyle{}
+			if row.entry.Favorite {
+				label.SetText("★ " + row.entry.Website)
+			} else {
+				label.SetText(row.entry.Website)
+			}
 		},
 	)
 
 	list.OnSelected = func(id widget.ListItemID) {
-		entries, err := mw.db.GetAllEntries()
-		if err != nil {
+		list.Unselect(id)
+		if id >= len(rows) || rows[id].header {
 			return
 		}
-		showPasswordDetails(mw.window, mw.db, mw.key, entries[id], list)
+		showPasswordDetails(mw, rows[id].entry)
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search website, username, category or notes")
+	search.OnChanged = func(text string) {
+		mw.filter.Query = text
+		mw.applyFilter()
 	}
+	mw.watchInput(search)
+
+	categoryChips := newFilterChips("Categories:", func(selected []int) {
+		mw.filter.Categories = selected
+		mw.applyFilter()
+	})
+	tagChips := newFilterChips("Tags:", func(selected []int) {
+		mw.filter.Tags = selected
+		mw.applyFilter()
+	})
+
+	mw.entries.OnChanged(func() {
+		categoryChips.update(mw.categoryChips())
+		tagChips.update(mw.tagChips())
+		rows = listRows(mw.entries.All(), mw.categories, mw.filter.Sort)
+		list.Refresh()
+	})
 
 	addBtn := widget.NewButtonWithIcon("Add Password", theme.ContentAddIcon(), func() {
-		showAddPasswordDialog(mw.window, mw.db, mw.key, func() {
-			list.Refresh()
-		})
+		showAddPasswordDialog(mw)
+	})
+
+	var sortOptions []string
+	for _, order := range db.SortOrders {
+		sortOptions = append(sortOptions, order.String())
+	}
+	sortSelect := widget.NewSelect(sortOptions, nil)
+	sortSelect.SetSelected(mw.filter.Sort.String())
+	sortSelect.OnChanged = func(selected string) {
+		order, err := db.ParseSortOrder(selected)
+		if err != nil {
+			return
+		}
+		mw.filter.Sort = order
+		if err := mw.db.SetSortOrder(order); err != nil {
+			dialog.ShowError(err, mw.window)
+		}
+		mw.applyFilter()
+	}
+
+	categoriesBtn := widget.NewButtonWithIcon("Categories", theme.FolderIcon(), func() {
+		showCategoryManager(mw)
 	})
 
 	changePassBtn := widget.NewButtonWithIcon("Change Master Password", theme.SettingsIcon(), func() {
-		showChangePasswordDialog(mw.window, mw.db)
+		showChangePasswordDialog(mw.window, mw.db, mw.session, mw.refresh, mw.touch)
+	})
+
+	exportBtn := widget.NewButtonWithIcon("Export", theme.DownloadIcon(), func() {
+		showExportDialog(mw)
+	})
+
+	importBtn := widget.NewButtonWithIcon("Import", theme.UploadIcon(), func() {
+		showImportDialog(mw)
 	})
 
+	lockBtn := widget.NewButtonWithIcon("Lock", theme.LogoutIcon(), mw.lock)
+
 	return container.NewBorder(
-		container.NewHBox(addBtn, changePassBtn),
+		container.NewVBox(container.NewHBox(addBtn, categoriesBtn, changePassBtn, exportBtn, importBtn, lockBtn), container.NewBorder(nil, nil, nil, sortSelect, search), categoryChips.content(), tagChips.content()),
 		nil,
 		nil,
 		nil,
@@ -88,33 +243,121 @@ func createPasswordTab(mw *MainWindow) fyne.CanvasObject {
 	)
 }
 
-func showPasswordDetails(parent fyne.Window, db *db.DB, key []byte, entry db.PasswordEntry, list *widget.List) {
+// showPasswordDetails shows entry and refreshes mw after it has been
+// modified from the dialog.
+func showPasswordDetails(mw *MainWindow, entry db.PasswordEntry) {
 	var showPassword bool
 	var visibilityBtn *widget.Button
 	var passwordEntry *widget.Entry
 
-	decrypted, err := crypto.Decrypt(entry.EncryptedPassword, key)
+	var decrypted []byte
+	err := mw.session.WithKey(func(key []byte) error {
+		var err error
+		decrypted, err = mw.db.DecryptPassword(key, entry)
+		return err
+	})
 	if err != nil {
-		dialog.ShowError(fmt.Errorf("decryption failed: %w", err), parent)
+		dialog.ShowError(fmt.Errorf("decryption failed: %w", err), mw.window)
 		return
 	}
 	defer crypto.ClearBytes(decrypted)
 
+	fields, err := loadFields(mw.db, mw.session, entry.ID)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+
+	tags, err := loadTags(mw.db, mw.session, entry.ID)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	tagsHeader := widget.NewLabel("Tags:")
+	tagsLabel := widget.NewLabel(tagNames(tags))
+	if len(tags) == 0 {
+		tagsHeader.Hide()
+		tagsLabel.Hide()
+	}
+
+	history, err := mw.db.GetHistory(entry.ID)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+
+	totpURI, err := loadTOTP(mw.db, mw.session, entry)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	totpHeader := widget.NewLabel("One-Time Code:")
+	var totpView fyne.CanvasObject = widget.NewLabel("")
+	stopTOTP := func() {}
+	if totpURI == "" {
+		totpHeader.Hide()
+		totpView.Hide()
+	} else if key, err := otp.Parse(totpURI); err != nil {
+		totpView = widget.NewLabel(err.Error())
+	} else {
+		totpView, stopTOTP = totpDetails(mw.clipboard, key)
+	}
+
 	passwordEntry = widget.NewPasswordEntry()
 	passwordEntry.SetText(string(decrypted))
 
+	// Usage and favorite changes reorder the list, so it is only refreshed
+	// once the dialog closes.
+	changed := false
+	markUsed := func() {
+		if err := mw.db.MarkUsed(entry.ID); err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+		changed = true
+	}
+
 	visibilityBtn = widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
 		showPassword = !showPassword
 		passwordEntry.Password = !showPassword
 		if showPassword {
 			visibilityBtn.SetIcon(theme.VisibilityOffIcon())
+			markUsed()
 		} else {
 			visibilityBtn.SetIcon(theme.VisibilityIcon())
 		}
 		passwordEntry.Refresh()
 	})
 
-	dialog.ShowCustom(
+	favoriteCheck := widget.NewCheck("Favorite", nil)
+	favoriteCheck.SetChecked(entry.Favorite)
+	favoriteCheck.OnChanged = func(favorite bool) {
+		if err := mw.db.SetFavorite(entry.ID, favorite); err != nil {
+			dialog.ShowError(err, mw.window)
+			return
+		}
+		changed = true
+	}
+
+	notesLabel := widget.NewLabel(entry.Notes)
+	notesLabel.Wrapping = fyne.TextWrapWord
+	notesHeader := widget.NewLabel("Notes:")
+	if entry.Notes == "" {
+		notesHeader.Hide()
+		notesLabel.Hide()
+	}
+
+	var details *dialog.CustomDialog
+	historyView := widget.NewAccordion(widget.NewAccordionItem("Password History",
+		historyDetails(mw, history, func() {
+			details.Hide()
+			mw.refresh()
+		})))
+	if len(history) == 0 {
+		historyView.Hide()
+	}
+
+	details = dialog.NewCustom(
 		"Password Details",
 		"Close",
 		container.NewVBox(
@@ -125,57 +368,86 @@ func showPasswordDetails(parent fyne.Window, db *db.DB, key []byte, entry db.Pas
 			widget.NewLabel("Password:"),
 			container.NewHBox(
 				passwordEntry,
+				visibilityBtn,
 				widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
-					parent.Clipboard().SetContent(string(decrypted))
+					mw.clipboard.Copy(passwordEntry.Text)
+					markUsed()
 				}),
 			),
+			favoriteCheck,
+			totpHeader,
+			totpView,
+			tagsHeader,
+			tagsLabel,
+			fieldDetails(mw.clipboard, fields),
+			notesHeader,
+			notesLabel,
+			historyView,
 			widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
-				showEditPasswordDialog(parent, db, key, entry, list)
+				showEditPasswordDialog(mw, entry)
 			}),
 			widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
-				confirm := dialog.NewConfirm("Delete Password", "Are you sure?", func(confirmed bool) {
+				confirm := dialog.NewConfirm("Delete Password", "Move this password to the trash?", func(confirmed bool) {
 					if confirmed {
-						if err := db.DeleteEntry(entry.ID); err != nil {
-							dialog.ShowError(err, parent)
+						if err := mw.db.DeleteEntry(entry.ID); err != nil {
+							dialog.ShowError(err, mw.window)
 							return
 						}
-						list.Refresh()
+						details.Hide()
+						mw.refresh()
 					}
-				}, parent)
+				}, mw.window)
 				confirm.Show()
 			}),
 		),
-		parent,
+		mw.window,
 	)
+	details.SetOnClosed(func() {
+		mw.untrackSecret(details)
+		stopTOTP()
+		if changed {
+			mw.refresh()
+		}
+	})
+	mw.trackSecret(details)
+	details.Show()
 }
 
-func showAddPasswordDialog(parent fyne.Window, db *db.DB, key []byte, onSuccess func()) {
+func showAddPasswordDialog(mw *MainWindow) {
 	var showPassword bool
 	var visibilityBtn *widget.Button
 	var password *widget.Entry
 	var strengthLabel *widget.Label
-	var categorySelect *widget.Select
 
 	website := widget.NewEntry()
 	username := widget.NewEntry()
 	password = widget.NewPasswordEntry()
+	notes := widget.NewMultiLineEntry()
+	notes.Wrapping = fyne.TextWrapWord
+	notes.SetMinRowsVisible(3)
+	totp := newTOTPEntry("")
+	fieldEditor := newFieldEditor(nil, mw.touch)
 
-	categories, err := db.GetCategories()
+	categories, err := loadCategories(mw.db, mw.session)
 	if err != nil {
-		dialog.ShowError(err, parent)
+		dialog.ShowError(err, mw.window)
 		return
 	}
-	categoryOptions := []string{"None"}
-	for _, cat := range categories {
-		categoryOptions = append(categoryOptions, cat.Name)
+	categorySelect, selectedCategory := newCategorySelect(categories, nil)
+
+	knownTags, err := loadTags(mw.db, mw.session, 0)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
 	}
-	categorySelect = widget.NewSelect(categoryOptions, nil)
+	tagEditor := newTagEditor(mw.window, nil, knownTags)
 
 	strengthLabel = widget.NewLabel("")
 	password.OnChanged = func(text string) {
 		strength := utils.EvaluatePasswordStrength(text)
 		strengthLabel.SetText(fmt.Sprintf("Strength: %d%%", strength))
 	}
+	mw.watchInput(website, username, password, notes, totp, &tagEditor.input.Entry)
 
 	visibilityBtn = widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
 		showPassword = !showPassword
@@ -193,96 +465,137 @@ func showAddPasswordDialog(parent fyne.Window, db *db.DB, key []byte, onSuccess
 		widget.NewFormItem("Username", username),
 		widget.NewFormItem("Password", container.NewHBox(password)),
 		widget.NewFormItem("Category", categorySelect),
+		widget.NewFormItem("Tags", tagEditor.content()),
+		widget.NewFormItem("Notes", notes),
+		widget.NewFormItem("TOTP", totp),
+		widget.NewFormItem("Fields", fieldEditor.content()),
 		widget.NewFormItem("", strengthLabel),
 	}
 
-	dialog.ShowForm(
+	var form dialog.Dialog
+	form = dialog.NewForm(
 		"Add New Password",
 		"Add",
 		"Cancel",
 		formItems,
 		func(confirmed bool) {
+			mw.untrackSecret(form)
 			if !confirmed {
 				return
 			}
 
 			if website.Text == "" || username.Text == "" || password.Text == "" {
-				dialog.ShowError(fmt.Errorf("all fields are required"), parent)
+				dialog.ShowError(fmt.Errorf("all fields are required"), mw.window)
 				return
 			}
 
-			var categoryID *int
-			if categorySelect.Selected != "None" {
-				for _, cat := range categories {
-					if cat.Name == categorySelect.Selected {
-						id := cat.ID
-						categoryID = &id
-						break
-					}
-				}
-			}
+			categoryID := selectedCategory()
 
-			encrypted, err := crypto.Encrypt([]byte(password.Text), key)
+			fields, err := fieldEditor.fields()
 			if err != nil {
-				dialog.ShowError(fmt.Errorf("encryption failed: %w", err), parent)
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			tags, err := tagEditor.tags()
+			if err != nil {
+				dialog.ShowError(err, mw.window)
 				return
 			}
 
-			err = db.AddEntry(website.Text, username.Text, encrypted, nil, categoryID)
+			err = mw.session.WithKey(func(key []byte) error {
+				return mw.db.WithTx(func(tx *db.Tx) error {
+					id, err := tx.AddEntry(key, website.Text, username.Text, []byte(password.Text), notes.Text, categoryID)
+					if err != nil {
+						return err
+					}
+					if err := tx.SetTOTP(key, id, totp.Text); err != nil {
+						return err
+					}
+					if err := tx.SetEntryTags(key, id, tags); err != nil {
+						return err
+					}
+					return tx.SetFields(key, id, fields)
+				})
+			})
 			if err != nil {
-				dialog.ShowError(err, parent)
+				dialog.ShowError(err, mw.window)
 				return
 			}
-			onSuccess()
+			mw.refresh()
 		},
-		parent,
+		mw.window,
 	)
+	mw.trackSecret(form)
+	form.Show()
 }
 
-func showEditPasswordDialog(parent fyne.Window, db *db.DB, key []byte, entry db.PasswordEntry, list *widget.List) {
+func showEditPasswordDialog(mw *MainWindow, entry db.PasswordEntry) {
 	var showPassword bool
 	var visibilityBtn *widget.Button
 	var password *widget.Entry
 	var strengthLabel *widget.Label
-	var categorySelect *widget.Select
 
 	website := widget.NewEntry()
 	website.SetText(entry.Website)
 	username := widget.NewEntry()
 	username.SetText(entry.Username)
+	notes := widget.NewMultiLineEntry()
+	notes.Wrapping = fyne.TextWrapWord
+	notes.SetMinRowsVisible(3)
+	notes.SetText(entry.Notes)
 	password = widget.NewPasswordEntry()
-	decrypted, err := crypto.Decrypt(entry.EncryptedPassword, key)
+	var decrypted []byte
+	err := mw.session.WithKey(func(key []byte) error {
+		var err error
+		decrypted, err = mw.db.DecryptPassword(key, entry)
+		return err
+	})
 	if err != nil {
-		dialog.ShowError(fmt.Errorf("decryption failed: %w", err), parent)
+		dialog.ShowError(fmt.Errorf("decryption failed: %w", err), mw.window)
 		return
 	}
 	defer crypto.ClearBytes(decrypted)
 	password.SetText(string(decrypted))
 
-	categories, err := db.GetCategories()
+	fields, err := loadFields(mw.db, mw.session, entry.ID)
 	if err != nil {
-		dialog.ShowError(err, parent)
+		dialog.ShowError(err, mw.window)
 		return
 	}
-	categoryOptions := []string{"None"}
-	for _, cat := range categories {
-		categoryOptions = append(categoryOptions, cat.Name)
+	fieldEditor := newFieldEditor(fields, mw.touch)
+
+	totpURI, err := loadTOTP(mw.db, mw.session, entry)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
 	}
-	categorySelect = widget.NewSelect(categoryOptions, nil)
-	if entry.CategoryID != nil {
-		for _, cat := range categories {
-			if cat.ID == *entry.CategoryID {
-				categorySelect.SetSelected(cat.Name)
-				break
-			}
-		}
+	totp := newTOTPEntry(totpURI)
+
+	categories, err := loadCategories(mw.db, mw.session)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
 	}
+	categorySelect, selectedCategory := newCategorySelect(categories, entry.CategoryID)
+
+	tags, err := loadTags(mw.db, mw.session, entry.ID)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	knownTags, err := loadTags(mw.db, mw.session, 0)
+	if err != nil {
+		dialog.ShowError(err, mw.window)
+		return
+	}
+	tagEditor := newTagEditor(mw.window, tags, knownTags)
 
 	strengthLabel = widget.NewLabel("")
 	password.OnChanged = func(text string) {
 		strength := utils.EvaluatePasswordStrength(text)
 		strengthLabel.SetText(fmt.Sprintf("Strength: %d%%", strength))
 	}
+	mw.watchInput(website, username, password, notes, totp, &tagEditor.input.Entry)
 
 	visibilityBtn = widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
 		showPassword = !showPassword
@@ -300,53 +613,71 @@ func showEditPasswordDialog(parent fyne.Window, db *db.DB, key []byte, entry db.
 		widget.NewFormItem("Username", username),
 		widget.NewFormItem("Password", container.NewHBox(password)),
 		widget.NewFormItem("Category", categorySelect),
+		widget.NewFormItem("Tags", tagEditor.content()),
+		widget.NewFormItem("Notes", notes),
+		widget.NewFormItem("TOTP", totp),
+		widget.NewFormItem("Fields", fieldEditor.content()),
 		widget.NewFormItem("", strengthLabel),
 	}
 
-	dialog.ShowForm(
+	var form dialog.Dialog
+	form = dialog.NewForm(
 		"Edit Password",
 		"Save",
 		"Cancel",
 		formItems,
 		func(confirmed bool) {
+			mw.untrackSecret(form)
 			if !confirmed {
 				return
 			}
 
 			if website.Text == "" || username.Text == "" || password.Text == "" {
-				dialog.ShowError(fmt.Errorf("all fields are required"), parent)
+				dialog.ShowError(fmt.Errorf("all fields are required"), mw.window)
 				return
 			}
 
-			var categoryID *int
-			if categorySelect.Selected != "None" {
-				for _, cat := range categories {
-					if cat.Name == categorySelect.Selected {
-						id := cat.ID
-						categoryID = &id
-						break
-					}
-				}
-			}
+			categoryID := selectedCategory()
 
-			encrypted, err := crypto.Encrypt([]byte(password.Text), key)
+			fields, err := fieldEditor.fields()
+			if err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			tags, err := tagEditor.tags()
 			if err != nil {
-				dialog.ShowError(fmt.Errorf("encryption failed: %w", err), parent)
+				dialog.ShowError(err, mw.window)
 				return
 			}
 
-			err = db.UpdateEntry(entry.ID, website.Text, username.Text, encrypted, nil, categoryID)
+			err = mw.session.WithKey(func(key []byte) error {
+				return mw.db.WithTx(func(tx *db.Tx) error {
+					err := tx.UpdateEntry(key, entry.ID, website.Text, username.Text, []byte(password.Text), notes.Text, categoryID)
+					if err != nil {
+						return err
+					}
+					if err := tx.SetTOTP(key, entry.ID, totp.Text); err != nil {
+						return err
+					}
+					if err := tx.SetEntryTags(key, entry.ID, tags); err != nil {
+						return err
+					}
+					return tx.SetFields(key, entry.ID, fields)
+				})
+			})
 			if err != nil {
-				dialog.ShowError(err, parent)
+				dialog.ShowError(err, mw.window)
 				return
 			}
-			list.Refresh()
+			mw.refresh()
 		},
-		parent,
+		mw.window,
 	)
+	mw.trackSecret(form)
+	form.Show()
 }
 
-func createGeneratorTab(app fyne.App) fyne.CanvasObject {
+func createGeneratorTab(mw *MainWindow) fyne.CanvasObject {
 	length := widget.NewSlider(8, 32)
 	length.SetValue(16)
 	lengthLabel := widget.NewLabel(fmt.Sprintf("Length: %d", 16))
@@ -383,7 +714,7 @@ func createGeneratorTab(app fyne.App) fyne.CanvasObject {
 		}
 		pass, err := utils.GeneratePassword(config)
 		if err != nil {
-			dialog.ShowError(err, app.NewWindow(""))
+			dialog.ShowError(err, mw.app.NewWindow(""))
 			return
 		}
 		result.SetText(pass)
@@ -391,7 +722,7 @@ func createGeneratorTab(app fyne.App) fyne.CanvasObject {
 
 	copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
 		if result.Text != "" {
-			app.Clipboard().SetContent(result.Text)
+			mw.clipboard.Copy(result.Text)
 		}
 	})
 
@@ -0,0 +1,17 @@
+package ui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// createSettingsTab gathers the vault-wide settings. Settings that belong to
+// a single view, such as trash retention, stay with that view.
+func createSettingsTab(mw *MainWindow) fyne.CanvasObject {
+	return container.NewVScroll(container.NewVBox(
+		widget.NewCard("Auto-Lock", "", autoLockSettings(mw)),
+		widget.NewCard("Clipboard", "", clipboardSettings(mw)),
+		widget.NewCard("Failed Logins", "", failedLoginSettings(mw)),
+	))
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"spms/crypto"
+	"spms/db"
+)
+
+// entryStore caches decrypted entries for a list so rendering never queries
+// the database. It is reloaded after the vault changes and then notifies
+// its listeners.
+type entryStore struct {
+	session   *crypto.Session
+	load      func(key []byte) ([]db.PasswordEntry, error)
+	entries   []db.PasswordEntry
+	listeners []func()
+}
+
+func newEntryStore(session *crypto.Session, load func(key []byte) ([]db.PasswordEntry, error)) *entryStore {
+	return &entryStore{session: session, load: load}
+}
+
+// Reload fetches the entries again. The cached entries are kept when
+// loading fails.
+func (s *entryStore) Reload() error {
+	var entries []db.PasswordEntry
+	err := s.session.WithKey(func(key []byte) error {
+		var err error
+		entries, err = s.load(key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.entries = entries
+	s.notify()
+	return nil
+}
+
+// Clear drops the cached entries, for example when the vault is locked.
+func (s *entryStore) Clear() {
+	s.entries = nil
+	s.notify()
+}
+
+func (s *entryStore) Len() int {
+	return len(s.entries)
+}
+
+// All returns the cached entries. The slice must not be modified.
+func (s *entryStore) All() []db.PasswordEntry {
+	return s.entries
+}
+
+// At returns the entry at index i of the cached list.
+func (s *entryStore) At(i int) (db.PasswordEntry, bool) {
+	if i < 0 || i >= len(s.entries) {
+		return db.PasswordEntry{}, false
+	}
+	return s.entries[i], true
+}
+
+// OnChanged registers fn to be called after the entries change.
+func (s *entryStore) OnChanged(fn func()) {
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *entryStore) notify() {
+	for _, fn := range s.listeners {
+		fn()
+	}
+}
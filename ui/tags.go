@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"slices"
+	"spms/crypto"
+	"spms/db"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// loadTags returns the tags of entryID, or every tag in use when entryID
+// is zero.
+func loadTags(database *db.DB, session *crypto.Session, entryID int) ([]db.Tag, error) {
+	var tags []db.Tag
+	err := session.WithKey(func(key []byte) error {
+		var err error
+		if entryID == 0 {
+			tags, err = database.GetTags(key)
+		} else {
+			tags, err = database.GetEntryTags(key, entryID)
+		}
+		return err
+	})
+	return tags, err
+}
+
+// tagEditor edits the tags of an entry inside a form. Known tags are
+// offered as suggestions.
+type tagEditor struct {
+	parent fyne.Window
+	names  []string
+	input  *widget.SelectEntry
+	chips  *fyne.Container
+}
+
+func newTagEditor(parent fyne.Window, tags, known []db.Tag) *tagEditor {
+	te := &tagEditor{parent: parent, chips: container.NewHBox()}
+
+	var suggestions []string
+	for _, t := range known {
+		suggestions = append(suggestions, t.Name)
+	}
+	te.input = widget.NewSelectEntry(suggestions)
+	te.input.SetPlaceHolder("Add tag")
+	te.input.OnSubmitted = func(string) {
+		te.addInput()
+	}
+
+	for _, t := range tags {
+		te.add(t.Name)
+	}
+	return te
+}
+
+func (te *tagEditor) addInput() {
+	if err := te.addNames(te.input.Text); err != nil {
+		dialog.ShowError(err, te.parent)
+		return
+	}
+	te.input.SetText("")
+}
+
+// addNames adds each tag of a comma-separated list.
+func (te *tagEditor) addNames(text string) error {
+	var names []string
+	for _, name := range strings.Split(text, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		name, err := db.NormalizeTag(name)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		te.add(name)
+	}
+	return nil
+}
+
+func (te *tagEditor) add(name string) {
+	if slices.ContainsFunc(te.names, func(n string) bool { return strings.EqualFold(n, name) }) {
+		return
+	}
+	te.names = append(te.names, name)
+
+	var chip *fyne.Container
+	removeBtn := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		te.names = slices.DeleteFunc(te.names, func(n string) bool { return n == name })
+		te.chips.Remove(chip)
+	})
+	removeBtn.Importance = widget.LowImportance
+	chip = container.NewHBox(widget.NewLabel(name), removeBtn)
+	te.chips.Add(chip)
+}
+
+func (te *tagEditor) content() fyne.CanvasObject {
+	addBtn := widget.NewButtonWithIcon("", theme.ContentAddIcon(), te.addInput)
+	return container.NewVBox(
+		container.NewBorder(nil, nil, nil, addBtn, te.input),
+		container.NewHScroll(te.chips),
+	)
+}
+
+// tags returns the edited tag names, including text typed but not yet
+// added.
+func (te *tagEditor) tags() ([]string, error) {
+	if err := te.addNames(te.input.Text); err != nil {
+		return nil, err
+	}
+	te.input.SetText("")
+	return te.names, nil
+}
+
+func tagNames(tags []db.Tag) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"spms/crypto"
+	"spms/db"
+	"spms/otp"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+func loadTOTP(database *db.DB, session *crypto.Session, entry db.PasswordEntry) (string, error) {
+	var uri string
+	err := session.WithKey(func(key []byte) error {
+		var err error
+		uri, err = database.DecryptTOTP(key, entry)
+		return err
+	})
+	return uri, err
+}
+
+// newTOTPEntry returns a masked entry for an otpauth:// URI or base32 secret.
+func newTOTPEntry(uri string) *widget.Entry {
+	entry := widget.NewPasswordEntry()
+	entry.SetPlaceHolder("otpauth:// URI or secret")
+	entry.SetText(uri)
+	entry.Validator = func(s string) error {
+		if s == "" {
+			return nil
+		}
+		_, err := otp.Parse(s)
+		return err
+	}
+	return entry
+}
+
+// totpDetails shows the current code of key with a countdown to the next
+// one. The returned stop function must be called when the view is closed.
+func totpDetails(clipboard *clipboardManager, key *otp.Key) (fyne.CanvasObject, func()) {
+	code := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true, Bold: true})
+	countdown := widget.NewProgressBar()
+	countdown.Max = float64(key.Period)
+	countdown.TextFormatter = func() string {
+		return time.Duration(countdown.Value * float64(time.Second)).String()
+	}
+
+	update := func() {
+		now := time.Now()
+		current, err := key.Code(now)
+		if err != nil {
+			code.SetText(err.Error())
+			return
+		}
+		code.SetText(current)
+		countdown.SetValue(key.Remaining(now).Seconds())
+	}
+	update()
+
+	copyBtn := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+		if current, err := key.Code(time.Now()); err == nil {
+			clipboard.Copy(current)
+		}
+	})
+
+	ticker := time.NewTicker(time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fyne.Do(update)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stop := func() {
+		ticker.Stop()
+		close(done)
+	}
+	return container.NewBorder(nil, countdown, nil, copyBtn, code), stop
+}
@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"fmt"
+	"spms/db"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// retentionOptions are the choices offered for automatically purging the
+// trash, in days. Zero keeps deleted entries until purged by hand.
+var retentionOptions = []int{0, 7, 30, 90, 365}
+
+func retentionLabel(days int) string {
+	if days == 0 {
+		return "Never"
+	}
+	return fmt.Sprintf("After %d days", days)
+}
+
+func createTrashTab(mw *MainWindow) fyne.CanvasObject {
+	list := widget.NewList(
+		mw.trash.Len,
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, widget.NewIcon(theme.DeleteIcon()),
+				container.NewHBox(
+					widget.NewButtonWithIcon("Restore", theme.HistoryIcon(), nil),
+					widget.NewButtonWithIcon("Delete Forever", theme.CancelIcon(), nil),
+				),
+				widget.NewLabel("Item"),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			entry, ok := mw.trash.At(id)
+			if !ok {
+				return
+			}
+			cont := obj.(*fyne.Container)
+			label := cont.Objects[0].(*widget.Label)
+			buttons := cont.Objects[2].(*fyne.Container)
+
+			deleted := ""
+			if entry.DeletedAt != nil {
+				deleted = entry.DeletedAt.Local().Format("2006-01-02 15:04")
+			}
+			label.SetText(fmt.Sprintf("%s (%s) — deleted %s", entry.Website, entry.Username, deleted))
+
+			buttons.Objects[0].(*widget.Button).OnTapped = func() {
+				if err := mw.db.RestoreEntry(entry.ID); err != nil {
+					dialog.ShowError(err, mw.window)
+					return
+				}
+				mw.refresh()
+			}
+			buttons.Objects[1].(*widget.Button).OnTapped = func() {
+				dialog.ShowConfirm("Delete Forever",
+					fmt.Sprintf("Permanently delete %s? This cannot be undone.", entry.Website),
+					func(confirmed bool) {
+						if !confirmed {
+							return
+						}
+						if err := mw.db.PurgeEntry(entry.ID); err != nil {
+							dialog.ShowError(err, mw.window)
+							return
+						}
+						mw.refresh()
+					}, mw.window)
+			}
+		},
+	)
+
+	mw.trash.OnChanged(list.Refresh)
+
+	retention, err := mw.db.TrashRetention()
+	if err != nil {
+		retention = db.DefaultTrashRetention
+	}
+	var options []string
+	for _, days := range retentionOptions {
+		options = append(options, retentionLabel(days))
+	}
+	retentionSelect := widget.NewSelect(options, nil)
+	retentionSelect.SetSelected(retentionLabel(int(retention / (24 * time.Hour))))
+	retentionSelect.OnChanged = func(selected string) {
+		for _, days := range retentionOptions {
+			if retentionLabel(days) == selected {
+				if err := mw.db.SetTrashRetention(time.Duration(days) * 24 * time.Hour); err != nil {
+					dialog.ShowError(err, mw.window)
+				}
+				return
+			}
+		}
+	}
+
+	emptyBtn := widget.NewButtonWithIcon("Empty Trash", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Empty Trash", "Permanently delete every entry in the trash?", func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := mw.db.EmptyTrash(); err != nil {
+				dialog.ShowError(err, mw.window)
+				return
+			}
+			mw.refresh()
+		}, mw.window)
+	})
+
+	return container.NewBorder(
+		container.NewHBox(emptyBtn, widget.NewLabel("Purge deleted entries:"), retentionSelect),
+		nil,
+		nil,
+		nil,
+		list,
+	)
+}
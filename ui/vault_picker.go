@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"spms/crypto"
+	"spms/vaults"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// vaultPicker lets the user switch the login window to another named vault
+// or create a new one.
+func vaultPicker(app fyne.App, window fyne.Window, vaultPath string, session *crypto.Session) fyne.CanvasObject {
+	names, err := vaults.List()
+	if err != nil {
+		names = nil
+	}
+
+	current := vaults.Name(vaultPath)
+	options := names
+	if current == "" {
+		// Vaults opened by path are listed by their path.
+		current = vaultPath
+		options = append([]string{vaultPath}, names...)
+	} else if !slices.Contains(names, current) {
+		options = append([]string{current}, names...)
+	}
+
+	switchTo := func(path string) {
+		next := CreateLoginWindow(app, path, session)
+		next.Show()
+		window.Close()
+	}
+
+	selector := widget.NewSelect(options, nil)
+	selector.SetSelected(current)
+	selector.OnChanged = func(selected string) {
+		if selected == current {
+			return
+		}
+		path, err := vaults.Path(selected)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		switchTo(path)
+	}
+
+	newBtn := widget.NewButtonWithIcon("New Vault", theme.ContentAddIcon(), func() {
+		name := widget.NewEntry()
+		name.Validator = vaults.ValidName
+		dialog.ShowForm("New Vault", "Create", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Name", name)},
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				if slices.Contains(names, name.Text) {
+					dialog.ShowError(fmt.Errorf("vault %q already exists", name.Text), window)
+					return
+				}
+				path, err := vaults.Path(name.Text)
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				switchTo(path)
+			}, window)
+	})
+
+	return container.NewBorder(nil, nil, widget.NewLabel("Vault:"), newBtn, selector)
+}
+
+// CreateLegacyVaultWindow asks whether to copy legacy, the vault.db of an
+// older version, to vaultPath before the login window for vaultPath opens.
+// The original file is never removed.
+func CreateLegacyVaultWindow(app fyne.App, legacy, vaultPath string, session *crypto.Session) fyne.Window {
+	window := app.NewWindow("SPMS")
+	window.Resize(fyne.NewSize(500, 200))
+
+	openLogin := func() {
+		next := CreateLoginWindow(app, vaultPath, session)
+		next.Show()
+		window.Close()
+	}
+
+	message := widget.NewLabel(fmt.Sprintf(
+		"A vault from an older version of SPMS was found at %s.\n\n"+
+			"Copy it to %s? The original file is left in place.", legacy, vaultPath))
+	message.Wrapping = fyne.TextWrapWord
+
+	copyBtn := widget.NewButton("Copy Vault", func() {
+		if err := vaults.CopyLegacy(legacy, vaultPath); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		openLogin()
+	})
+	copyBtn.Importance = widget.HighImportance
+	skipBtn := widget.NewButton("Start Without It", openLogin)
+
+	window.SetContent(container.NewBorder(nil, container.NewHBox(layout.NewSpacer(), skipBtn, copyBtn), nil, nil, message))
+	return window
+}
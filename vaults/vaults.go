@@ -0,0 +1,208 @@
+// Package vaults locates vault databases. Vaults are either referred to by
+// path or by name, in which case they live in the user's data directory.
+package vaults
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"spms/db"
+	"strings"
+)
+
+// EnvVault names the environment variable that selects the vault when no
+// flag is given. It holds a vault name or path.
+const EnvVault = "SPMS_VAULT"
+
+// DefaultName is the vault opened when nothing else is selected.
+const DefaultName = "default"
+
+const extension = ".db"
+
+// legacyFile is the vault file older versions created in the working
+// directory.
+const legacyFile = "vault.db"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]*$`)
+
+// DataDir returns the directory holding named vaults: $XDG_DATA_HOME/spms,
+// ~/.local/share/spms on other Unix systems, and the user config directory
+// on Windows and macOS.
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "spms"), nil
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "spms"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "spms"), nil
+}
+
+// ValidName reports whether name can be used as a vault name.
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid vault name %q", name)
+	}
+	return nil
+}
+
+// Path returns the file of the named vault, creating the data directory if
+// needed.
+func Path(name string) (string, error) {
+	if err := ValidName(name); err != nil {
+		return "", err
+	}
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return filepath.Join(dir, name+extension), nil
+}
+
+// Name returns the vault name of path if it is a named vault, or "" if it
+// lives elsewhere.
+func Name(path string) string {
+	dir, err := DataDir()
+	if err != nil || filepath.Dir(filepath.Clean(path)) != dir || filepath.Ext(path) != extension {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(path), extension)
+}
+
+// List returns the names of the vaults in the data directory.
+func List() ([]string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), extension)
+		if f.Type().IsRegular() && filepath.Ext(f.Name()) == extension && ValidName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Resolve returns the vault file to open. value comes from a command-line
+// flag and takes precedence over $SPMS_VAULT; either may be a name or a
+// path. Without both, the default named vault is used.
+func Resolve(value string) (string, error) {
+	if value == "" {
+		value = os.Getenv(EnvVault)
+	}
+	if value == "" {
+		return Path(DefaultName)
+	}
+	if isPath(value) {
+		return filepath.Abs(value)
+	}
+	return Path(value)
+}
+
+func isPath(value string) bool {
+	return strings.ContainsRune(value, filepath.Separator) || strings.ContainsRune(value, '/') ||
+		filepath.Ext(value) == extension
+}
+
+// FindLegacy returns the vault.db older versions kept in the working
+// directory, or next to the executable, when it can take the place of the
+// default vault: no vault was selected with value or $SPMS_VAULT, the
+// default vault does not exist yet and the file is an SPMS vault.
+func FindLegacy(value string) (string, bool) {
+	if value != "" || os.Getenv(EnvVault) != "" {
+		return "", false
+	}
+	path, err := Path(DefaultName)
+	if err != nil {
+		return "", false
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return "", false
+	}
+
+	var candidates []string
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, legacyFile))
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), legacyFile))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() && db.IsVault(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// CopyLegacy copies the vault from and its write-ahead log to to. The
+// original is left in place, and a failure removes the partial copy.
+func CopyLegacy(from, to string) error {
+	var suffixes []string
+	for _, suffix := range []string{"", "-wal"} {
+		if _, err := os.Stat(from + suffix); err == nil {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	// The shared-memory index is rebuilt by SQLite and is not copied.
+	for i, suffix := range suffixes {
+		if err := copyFile(from+suffix, to+suffix); err != nil {
+			for _, copied := range suffixes[:i] {
+				os.Remove(to + copied)
+			}
+			return fmt.Errorf("failed to copy %s to %s: %w", from, to, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to)
+	}
+	return err
+}